@@ -0,0 +1,34 @@
+// Command auditverify recomputes the audit log's hash chain and reports the
+// first entry that was altered, inserted or removed.
+//
+//	go run ./cmd/auditverify
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"gotestbackend/database"
+	"gotestbackend/services"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	// SQL logging goes to stdout, which carries the result
+	db := database.SetupDB().Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+	result, err := services.VerifyAuditChain(db)
+	if err != nil {
+		log.Fatalf("Verification failed: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		log.Fatalf("Could not write result: %v", err)
+	}
+	if !result.Valid {
+		os.Exit(1)
+	}
+}
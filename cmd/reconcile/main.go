@@ -0,0 +1,48 @@
+// Command reconcile replays every account's transactions and reports where the
+// stored balances disagree with the ledger.
+//
+//	go run ./cmd/reconcile -format csv > report.csv
+//	go run ./cmd/reconcile -fix
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"gotestbackend/database"
+	"gotestbackend/services"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	format := flag.String("format", "json", "report format: json or csv")
+	fix := flag.Bool("fix", false, "post adjusting entries for credit mismatches")
+	flag.Parse()
+
+	// SQL logging goes to stdout, which carries the report
+	db := database.SetupDB().Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+	report, err := services.Reconcile(db, *fix)
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+	switch *format {
+	case "csv":
+		err = services.WriteReconciliationCSV(os.Stdout, report)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	default:
+		log.Fatalf("Unknown format %q", *format)
+	}
+	if err != nil {
+		log.Fatalf("Could not write report: %v", err)
+	}
+	if len(report.Discrepancies) > 0 && !*fix {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,45 @@
+// Command statementverify checks a downloaded statement's signature without
+// contacting the server. The key is the public_key from
+// /api/accounting/statement/public-key.
+//
+//	go run ./cmd/statementverify -key <base64 public key> statement.json
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gotestbackend/services"
+)
+
+func main() {
+	keyFlag := flag.String("key", "", "base64 Ed25519 public key")
+	flag.Parse()
+	if *keyFlag == "" || flag.NArg() != 1 {
+		log.Fatalf("Usage: statementverify -key <base64 public key> statement.json")
+	}
+	key, err := base64.StdEncoding.DecodeString(*keyFlag)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		log.Fatalf("Invalid public key")
+	}
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Could not read statement: %v", err)
+	}
+	var signed services.SignedStatement
+	if err := json.Unmarshal(data, &signed); err != nil {
+		log.Fatalf("Could not parse statement: %v", err)
+	}
+	statement, err := services.VerifyStatement(signed, ed25519.PublicKey(key))
+	if err != nil {
+		fmt.Println("INVALID: the statement was altered or not signed by this key")
+		os.Exit(1)
+	}
+	fmt.Printf("VALID: account %s, %s to %s, %d lines, closing balance %.2f\n",
+		statement.AccountNumber, statement.From, statement.To, len(statement.Lines), statement.ClosingBalance)
+}
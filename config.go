@@ -0,0 +1,280 @@
+package main
+
+import (
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotestbackend/services"
+	"gotestbackend/services/fakerail"
+	"gotestbackend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// envInt overrides *target with the named variable when it is set. A value
+// that does not parse stops the server rather than silently keeping the default.
+func envInt(name string, target *int) {
+	if value := os.Getenv(name); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("Invalid %s %q: must be a whole number", name, value)
+		}
+		*target = parsed
+	}
+}
+
+// envFloat overrides *target with the named variable when it is set
+func envFloat(name string, target *float64) {
+	if value := os.Getenv(name); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			log.Fatalf("Invalid %s %q: must be a number", name, value)
+		}
+		*target = parsed
+	}
+}
+
+// envBool overrides *target with the named variable when it is set, e.g. to true or 1
+func envBool(name string, target *bool) {
+	if value := os.Getenv(name); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Invalid %s %q: must be true or false", name, value)
+		}
+		*target = parsed
+	}
+}
+
+// envDuration overrides *target with the named variable when it is set, e.g. 10m or 1h
+func envDuration(name string, target *time.Duration) {
+	if value := os.Getenv(name); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid %s %q: must be a duration such as 10m or 1h", name, value)
+		}
+		*target = parsed
+	}
+}
+
+// envString overrides *target with the named variable when it is set
+func envString(name string, target *string) {
+	if value := os.Getenv(name); value != "" {
+		*target = value
+	}
+}
+
+// configureFraudRules tunes the rules transfers are checked against. Each
+// action is allow, challenge or block, and a zero threshold turns its rule off:
+//
+//	FRAUD_VELOCITY_MAX_TRANSFERS=5       FRAUD_VELOCITY_WINDOW=10m   FRAUD_VELOCITY_ACTION=block
+//	FRAUD_NEW_RECEIVER_AMOUNT=10000      FRAUD_NEW_RECEIVER_ACTION=challenge
+//	FRAUD_HISTORY_MULTIPLIER=5           FRAUD_HISTORY_MIN_TRANSFERS=3   FRAUD_HISTORY_ACTION=challenge
+//	FRAUD_FUNNEL_MAX_SENDERS=10          FRAUD_FUNNEL_WINDOW=1h      FRAUD_FUNNEL_ACTION=challenge
+func configureFraudRules() {
+	rules := &services.FraudRules
+	envInt("FRAUD_VELOCITY_MAX_TRANSFERS", &rules.VelocityMaxTransfers)
+	envDuration("FRAUD_VELOCITY_WINDOW", &rules.VelocityWindow)
+	envString("FRAUD_VELOCITY_ACTION", &rules.VelocityAction)
+	envFloat("FRAUD_NEW_RECEIVER_AMOUNT", &rules.NewReceiverAmount)
+	envString("FRAUD_NEW_RECEIVER_ACTION", &rules.NewReceiverAction)
+	envFloat("FRAUD_HISTORY_MULTIPLIER", &rules.HistoryMultiplier)
+	envInt("FRAUD_HISTORY_MIN_TRANSFERS", &rules.HistoryMinTransfers)
+	envString("FRAUD_HISTORY_ACTION", &rules.HistoryAction)
+	envInt("FRAUD_FUNNEL_MAX_SENDERS", &rules.FunnelMaxSenders)
+	envDuration("FRAUD_FUNNEL_WINDOW", &rules.FunnelWindow)
+	envString("FRAUD_FUNNEL_ACTION", &rules.FunnelAction)
+	if err := services.ValidateFraudRules(*rules); err != nil {
+		log.Fatalf("Invalid fraud rules: %v", err)
+	}
+}
+
+// configureAdjustments sets when balance adjustments need a second approver:
+//
+//	ADJUSTMENT_DUAL_APPROVAL_AMOUNT=10000   amount from which two admins must approve
+func configureAdjustments() {
+	envFloat("ADJUSTMENT_DUAL_APPROVAL_AMOUNT", &services.AdjustmentDualApprovalAmount)
+	if services.AdjustmentDualApprovalAmount <= 0 {
+		log.Fatalf("ADJUSTMENT_DUAL_APPROVAL_AMOUNT must be positive")
+	}
+}
+
+// configureContacts sets whether contacts must be verified before sending money:
+//
+//	REQUIRE_VERIFIED_CONTACT=false   block transfers from users without a verified email or phone
+func configureContacts() {
+	envBool("REQUIRE_VERIFIED_CONTACT", &services.RequireVerifiedContact)
+}
+
+// configurePasswordPolicy sets what passwords must satisfy:
+//
+//	PASSWORD_MIN_LENGTH=10    shortest accepted password
+//	PASSWORD_MAX_LENGTH=72    longest accepted password, at most 72 as bcrypt ignores the rest
+//	PASSWORD_MIN_CLASSES=3    how many of lower case, upper case, digits and symbols must appear
+func configurePasswordPolicy() {
+	policy := &services.PasswordPolicy
+	envInt("PASSWORD_MIN_LENGTH", &policy.MinLength)
+	envInt("PASSWORD_MAX_LENGTH", &policy.MaxLength)
+	envInt("PASSWORD_MIN_CLASSES", &policy.MinClasses)
+	if policy.MinLength < 1 || policy.MaxLength < policy.MinLength || policy.MaxLength > 72 {
+		log.Fatalf("Password lengths must satisfy 1 <= PASSWORD_MIN_LENGTH <= PASSWORD_MAX_LENGTH <= 72")
+	}
+	if policy.MinClasses < 0 || policy.MinClasses > 4 {
+		log.Fatalf("PASSWORD_MIN_CLASSES must be between 0 and 4")
+	}
+}
+
+// configurePIN sets when transfers need the transaction PIN:
+//
+//	PIN_THRESHOLD=5000   transfer amount from which the PIN is required
+func configurePIN() {
+	envFloat("PIN_THRESHOLD", &services.PINThreshold)
+	if services.PINThreshold < 0 {
+		log.Fatalf("PIN_THRESHOLD must not be negative")
+	}
+}
+
+// configureDormancy sets when the nightly job marks idle accounts dormant:
+//
+//	DORMANCY_DAYS=365   days without activity before an active account goes dormant
+func configureDormancy() {
+	envInt("DORMANCY_DAYS", &services.DormancyDays)
+	if services.DormancyDays <= 0 {
+		log.Fatalf("DORMANCY_DAYS must be positive")
+	}
+}
+
+// configureOverdraft sets when the nightly job flags overdrawn accounts:
+//
+//	OVERDRAFT_GRACE_DAYS=30   days an account may stay overdrawn before it is flagged
+func configureOverdraft() {
+	envInt("OVERDRAFT_GRACE_DAYS", &services.OverdraftGraceDays)
+	if services.OverdraftGraceDays < 0 {
+		log.Fatalf("OVERDRAFT_GRACE_DAYS must not be negative")
+	}
+}
+
+// configureAccountNumbers sets how new account numbers are allocated:
+//
+//	ACCOUNT_NUMBER_PREFIX=10   digits every new account number starts with
+func configureAccountNumbers() {
+	prefix := os.Getenv("ACCOUNT_NUMBER_PREFIX")
+	if prefix == "" {
+		return
+	}
+	if _, err := strconv.ParseUint(prefix, 10, 64); err != nil || len(prefix) > utils.AccountNumberLength-2 {
+		log.Fatalf("Invalid ACCOUNT_NUMBER_PREFIX %q: must be 1 to %d digits", prefix, utils.AccountNumberLength-2)
+	}
+	// System accounts are numbered under 99
+	if strings.HasPrefix(prefix, "99") {
+		log.Fatalf("Invalid ACCOUNT_NUMBER_PREFIX %q: 99 is reserved for system accounts", prefix)
+	}
+	services.AccountNumberPrefix = prefix
+}
+
+// configurePaymentRails registers the payment rails enabled in the environment.
+// The fake rail settles every payment it is given, so it is only registered
+// when FAKE_PAYMENT_RAIL=1 is set for development or testing:
+//
+//	FAKE_PAYMENT_RAIL=1                  enable the fake rail and make it the default
+//	FAKE_PAYMENT_RAIL_SECRET=...         HMAC secret its callbacks are signed with (required)
+//	FAKE_PAYMENT_RAIL_CALLBACK_URL=...   where it posts callbacks, default the local server
+func configurePaymentRails() {
+	if os.Getenv("FAKE_PAYMENT_RAIL") != "1" {
+		return
+	}
+	secret := os.Getenv("FAKE_PAYMENT_RAIL_SECRET")
+	if len(secret) < 32 {
+		log.Fatalf("FAKE_PAYMENT_RAIL_SECRET must be set to at least 32 characters when FAKE_PAYMENT_RAIL=1")
+	}
+	callbackURL := os.Getenv("FAKE_PAYMENT_RAIL_CALLBACK_URL")
+	if callbackURL == "" {
+		callbackURL = "http://localhost:8080/api/payments/callbacks/fake"
+	}
+	services.PaymentRails["fake"] = fakerail.New([]byte(secret), callbackURL, time.Second)
+	services.DefaultPaymentRail = "fake"
+	log.Printf("Fake payment rail enabled: deposits and withdrawals are not real")
+}
+
+// configureTrustedProxies decides whose X-Forwarded-For gin believes. Login
+// throttling, API key allowlists and the audit log all key on c.ClientIP(),
+// so by default no proxy is trusted and it is the connection's address.
+// Behind a load balancer, list its addresses or CIDRs:
+//
+//	TRUSTED_PROXIES=10.0.0.0/8,192.168.1.2
+func configureTrustedProxies(r *gin.Engine) {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+}
+
+// configureStatementKey loads the Ed25519 key statements are signed with, as a
+// hex 32-byte seed. The server refuses to start without one:
+//
+//	STATEMENT_SIGNING_KEY=...        the seed itself
+//	STATEMENT_SIGNING_KEY_FILE=...   or a file holding it
+func configureStatementKey() {
+	seed := os.Getenv("STATEMENT_SIGNING_KEY")
+	if path := os.Getenv("STATEMENT_SIGNING_KEY_FILE"); seed == "" && path != "" {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Could not read STATEMENT_SIGNING_KEY_FILE: %v", err)
+		}
+		seed = string(contents)
+	}
+	if seed == "" {
+		log.Fatalf("STATEMENT_SIGNING_KEY or STATEMENT_SIGNING_KEY_FILE must be set")
+	}
+	key, err := services.ParseStatementKey(seed)
+	if err != nil {
+		log.Fatalf("Invalid statement signing key: %v", err)
+	}
+	services.StatementSigningKey = key
+}
+
+// configureBootstrapAdmin creates the first admin when there is none, so a
+// fresh deployment has no built-in admin login:
+//
+//	ADMIN_BOOTSTRAP_USERNAME=...   username of the first admin
+//	ADMIN_BOOTSTRAP_PASSWORD=...   one-time password; logging in with it only returns a reset token
+//
+// Remove both once the admin has set a password.
+func configureBootstrapAdmin(db *gorm.DB) {
+	username, password := os.Getenv("ADMIN_BOOTSTRAP_USERNAME"), os.Getenv("ADMIN_BOOTSTRAP_PASSWORD")
+	if username == "" && password == "" {
+		return
+	}
+	if username == "" || password == "" {
+		log.Fatalf("ADMIN_BOOTSTRAP_USERNAME and ADMIN_BOOTSTRAP_PASSWORD must be set together")
+	}
+	created, err := services.BootstrapAdmin(db, username, password)
+	if err != nil {
+		log.Fatalf("Could not create bootstrap admin: %v", err)
+	}
+	if created {
+		log.Printf("Created bootstrap admin %s; its password must be reset at first login", username)
+	}
+}
+
+// configureOIDCProviders loads the OpenID Connect providers users can log in
+// with; see services.LoadOIDCProviders for the variables
+func configureOIDCProviders() {
+	providers, err := services.LoadOIDCProviders(os.Getenv)
+	if err != nil {
+		log.Fatalf("Invalid OIDC configuration: %v", err)
+	}
+	services.OIDCProviders = providers
+	for name, provider := range providers {
+		log.Printf("OIDC provider %s enabled (%s)", name, provider.Issuer)
+	}
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReserveAccountNumbersPayload is used to bind an account number reservation
+type ReserveAccountNumbersPayload struct {
+	// Prefix defaults to the prefix used for new accounts
+	Prefix        string `json:"prefix"`
+	FirstSequence uint64 `json:"first_sequence" binding:"required"`
+	LastSequence  uint64 `json:"last_sequence" binding:"required"`
+	Reason        string `json:"reason" binding:"required"`
+}
+
+// ReserveAccountNumbers keeps a range of account numbers out of automatic allocation
+//
+//	@Summary		reserveAccountNumbers
+//	@Description	Reserves a range of sequence values so Register never hands them out
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ReserveAccountNumbersPayload	true	"Reservation"
+//	@Success		201		{object}	models.AccountNumberReservation
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/account-numbers/reservations [post]
+func ReserveAccountNumbers(c *gin.Context) {
+	var payload ReserveAccountNumbersPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	adminID, _ := c.Get("user_id")
+	reservation := models.AccountNumberReservation{
+		Prefix:        payload.Prefix,
+		FirstSequence: payload.FirstSequence,
+		LastSequence:  payload.LastSequence,
+		Reason:        payload.Reason,
+		ReservedBy:    adminID.(uint),
+	}
+	if err := services.ReserveAccountNumbers(database.DB, &reservation); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidReservation):
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		case errors.Is(err, services.ErrReservationOverlap):
+			c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not save reservation"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, reservation)
+}
+
+// GetAccountNumberReservations lists reserved account number ranges
+//
+//	@Summary		getAccountNumberReservations
+//	@Description	Lists reserved account number ranges
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	[]models.AccountNumberReservation
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/account-numbers/reservations [get]
+func GetAccountNumberReservations(c *gin.Context) {
+	var reservations []models.AccountNumberReservation
+	if err := database.DB.Order("prefix, first_sequence").Find(&reservations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch reservations"})
+		return
+	}
+	c.JSON(http.StatusOK, reservations)
+}
+
+// DeleteAccountNumberReservation releases a reserved range
+//
+//	@Summary		deleteAccountNumberReservation
+//	@Description	Releases a reserved range back to automatic allocation
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string				true	"Reservation ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Router			/admin/account-numbers/reservations/{id} [delete]
+func DeleteAccountNumberReservation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid reservation ID"})
+		return
+	}
+	result := database.DB.Delete(&models.AccountNumberReservation{}, uint(id))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete reservation"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Reservation not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Reservation released"})
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateAccountStatusPayload is used to bind an account status change
+type UpdateAccountStatusPayload struct {
+	Status string `json:"status" binding:"required" enums:"active,frozen,dormant,closed"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+// UpdateAccountStatus moves a user's account to another lifecycle state
+//
+//	@Summary		updateAccountStatus
+//	@Description	Freezes, reactivates, marks dormant or closes an account. Closed is final and needs zero credit and no held funds
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string						true	"User ID"
+//	@Param			payload	body		UpdateAccountStatusPayload	true	"New status"
+//	@Success		200		{object}	models.User
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/status [put]
+func UpdateAccountStatus(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var payload UpdateAccountStatusPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if !services.IsValidAccountStatus(payload.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown status"})
+		return
+	}
+	adminID, _ := c.Get("user_id")
+	user, err := services.ChangeAccountStatus(database.DB, id, payload.Status, payload.Reason, adminID.(uint))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidStatusTransition) {
+			c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrAccountNotSettled) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Balance and pending withdrawals must be settled before the account can be closed"})
+			return
+		}
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	user, err = GetDataUser(user.ID)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// GetAccountStatusHistory lists every status change of a user's account
+//
+//	@Summary		getAccountStatusHistory
+//	@Description	Lists status changes of an account, newest first
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string	true	"User ID"
+//	@Success		200	{object}	[]models.AccountStatusChange
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/status-history [get]
+func GetAccountStatusHistory(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var changes []models.AccountStatusChange
+	if err := database.DB.Where("user_id = ?", id).Order("created_at DESC, id DESC").Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch status history"})
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+// RestoreUser brings back a soft-deleted user
+//
+//	@Summary		restoreUser
+//	@Description	Restores a soft-deleted user so they can log in and receive transfers again
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string	true	"User ID"
+//	@Success		200	{object}	models.User
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/restore [post]
+func RestoreUser(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	user, err := services.RestoreUser(database.DB, id)
+	if err != nil {
+		respondUserLookupError(c, err, "Deleted user not found")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RequestAdjustmentPayload is used to bind a balance adjustment request
+type RequestAdjustmentPayload struct {
+	// Direction is credit or debit
+	Direction string  `json:"direction" binding:"required"`
+	Amount    float64 `json:"amount" binding:"required"`
+	Reason    string  `json:"reason" binding:"required"`
+	// EvidenceRef points at the ticket, email or document behind the adjustment
+	EvidenceRef string `json:"evidence_ref" binding:"required"`
+}
+
+// respondAdjustmentError maps adjustment errors onto responses
+func respondAdjustmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAdjustment):
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Adjustment not found"})
+	case errors.Is(err, services.ErrSelfApproval):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Adjustments must be approved by a different admin"})
+	case errors.Is(err, services.ErrAdjustmentNotPending):
+		c.JSON(http.StatusConflict, gin.H{"message": "Adjustment was already posted or rejected"})
+	case errors.Is(err, services.ErrAlreadyDecided):
+		c.JSON(http.StatusConflict, gin.H{"message": "You already approved this adjustment"})
+	case errors.Is(err, services.ErrAdjustmentInsufficientCredit):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "Account does not have enough available credit for this debit"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to process adjustment"})
+	}
+}
+
+// parseAdjustmentID reads the :id path parameter of an adjustment
+func parseAdjustmentID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid adjustment ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// RequestAdjustment asks for a credit or debit to a user's balance
+//
+//	@Summary		requestAdjustment
+//	@Description	Records a balance adjustment for another admin to approve. From services.AdjustmentDualApprovalAmount it needs two approvers
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string						true	"User ID"
+//	@Param			payload	body		RequestAdjustmentPayload	true	"Adjustment"
+//	@Success		201		{object}	models.BalanceAdjustment
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/adjustments [post]
+func RequestAdjustment(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var payload RequestAdjustmentPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	adminID, _ := c.Get("user_id")
+	adjustment, err := services.RequestAdjustment(database.DB, adminID.(uint), id, payload.Direction, payload.Amount, payload.Reason, payload.EvidenceRef)
+	if err != nil {
+		respondAdjustmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, adjustment)
+}
+
+// GetAdjustments lists balance adjustments with their decisions
+//
+//	@Summary		getAdjustments
+//	@Description	Lists balance adjustments and every approval or rejection, newest first. Filter by status pending, posted or rejected, and by user
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			status	query		string	false	"pending, posted or rejected"
+//	@Param			user_id	query		int		false	"Adjusted user"
+//	@Success		200		{object}	[]models.BalanceAdjustment
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/adjustments [get]
+func GetAdjustments(c *gin.Context) {
+	query := database.DB.Preload("Decisions", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).Order("id DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if user := c.Query("user_id"); user != "" {
+		userID, err := strconv.ParseUint(user, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user_id"})
+			return
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+	var adjustments []models.BalanceAdjustment
+	if err := query.Find(&adjustments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch adjustments"})
+		return
+	}
+	c.JSON(http.StatusOK, adjustments)
+}
+
+// GetAdjustment shows one balance adjustment with its decisions
+//
+//	@Summary		getAdjustment
+//	@Description	Shows a balance adjustment and every approval or rejection
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Adjustment ID"
+//	@Success		200	{object}	models.BalanceAdjustment
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Router			/admin/adjustments/{id} [get]
+func GetAdjustment(c *gin.Context) {
+	id, ok := parseAdjustmentID(c)
+	if !ok {
+		return
+	}
+	adjustment, err := services.GetAdjustment(database.DB, id)
+	if err != nil {
+		respondAdjustmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, adjustment)
+}
+
+// ApproveAdjustment approves a pending balance adjustment
+//
+//	@Summary		approveAdjustment
+//	@Description	Approves an adjustment requested by another admin. The last approval needed posts it as a ledger transaction against the house adjustments account
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Adjustment ID"
+//	@Param			payload	body		ReviewDecisionPayload	false	"Note"
+//	@Success		200		{object}	models.BalanceAdjustment
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		422		{object}	map[string]string	"message"
+//	@Router			/admin/adjustments/{id}/approve [post]
+func ApproveAdjustment(c *gin.Context) {
+	decideAdjustment(c, true)
+}
+
+// RejectAdjustment rejects a pending balance adjustment
+//
+//	@Summary		rejectAdjustment
+//	@Description	Rejects an adjustment so it never posts. The requesting admin may reject their own request to withdraw it
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Adjustment ID"
+//	@Param			payload	body		ReviewDecisionPayload	false	"Note"
+//	@Success		200		{object}	models.BalanceAdjustment
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Router			/admin/adjustments/{id}/reject [post]
+func RejectAdjustment(c *gin.Context) {
+	decideAdjustment(c, false)
+}
+
+// decideAdjustment records an admin's approval or rejection of an adjustment
+func decideAdjustment(c *gin.Context, approve bool) {
+	id, ok := parseAdjustmentID(c)
+	if !ok {
+		return
+	}
+	var payload ReviewDecisionPayload
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	adminID, _ := c.Get("user_id")
+	decide := services.RejectAdjustment
+	if approve {
+		decide = services.ApproveAdjustment
+	}
+	adjustment, err := decide(database.DB, id, adminID.(uint), payload.Note)
+	if err != nil {
+		respondAdjustmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, adjustment)
+}
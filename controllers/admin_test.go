@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/middlewares"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminRoutesNeedAnMFAVerifiedSession(t *testing.T) {
+	db := testutil.NewDB(t)
+	admin := testutil.CreateUser(t, db, "admin", "123456789", 0)
+	if err := db.Model(&admin).Update("role", models.RoleAdmin).Error; err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/admin/ping", middlewares.JWTAuthMiddleware(), middlewares.AdminMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	tokenFor := func(mfaVerified bool) string {
+		t.Helper()
+		session, err := services.CreateSession(db, admin.ID, "test", "127.0.0.1", "go-test", time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := middlewares.GenerateToken(admin.ID, session.ID, session.ExpiresAt, mfaVerified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	call := func(token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/admin/ping", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A password-only session from before enrolment
+	early := tokenFor(false)
+	now := time.Now()
+	if err := db.Create(&models.MFAEnrollment{UserID: admin.ID, Secret: "x", ConfirmedAt: &now}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if code := call(early); code != http.StatusForbidden {
+		t.Errorf("session issued before enrolment: status %d, want 403", code)
+	}
+	if code := call(tokenFor(true)); code != http.StatusNoContent {
+		t.Errorf("MFA-verified session: status %d, want 204", code)
+	}
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AliasPayload is used to bind an alias
+type AliasPayload struct {
+	AliasType  string `json:"alias_type" binding:"required"`
+	AliasValue string `json:"alias_value" binding:"required"`
+}
+
+// resolveReceiverAccount returns the account to pay: the account number if
+// given, otherwise the one the alias resolves to. It answers the request
+// itself and returns false when neither works.
+func resolveReceiverAccount(c *gin.Context, account, aliasType, aliasValue string) (string, bool) {
+	if account != "" {
+		return account, true
+	}
+	if aliasType == "" || aliasValue == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "receiver_account, or alias_type and alias_value, is required"})
+		return "", false
+	}
+	resolved, err := services.ResolveAlias(database.DB, aliasType, aliasValue)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidAlias), errors.Is(err, services.ErrInvalidContact):
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		case errors.Is(err, services.ErrAliasNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"message": "Receiver not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to resolve alias"})
+		}
+		return "", false
+	}
+	return resolved, true
+}
+
+// GetAliases lists the logged-in user's aliases
+//
+//	@Summary		getAliases
+//	@Description	Lists the phone, email and username aliases payers can use instead of the account number
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	[]models.Alias
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/aliases [get]
+func GetAliases(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var aliases []models.Alias
+	if err := database.DB.Where("user_id = ?", userID).Order("id").Find(&aliases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch aliases"})
+		return
+	}
+	c.JSON(http.StatusOK, aliases)
+}
+
+// CreateAlias registers an alias for the logged-in user's account
+//
+//	@Summary		createAlias
+//	@Description	Registers a phone, email or username alias for the user's account. Phone and email must be the user's verified contact; the alias stops resolving if that contact changes
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		AliasPayload	true	"Alias"
+//	@Success		201		{object}	models.Alias
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/user/me/aliases [post]
+func CreateAlias(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var payload AliasPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	user, err := GetDataUser(userID.(uint))
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	alias, err := services.RegisterAlias(database.DB, user, payload.AliasType, payload.AliasValue)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidAlias), errors.Is(err, services.ErrInvalidContact):
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		case errors.Is(err, services.ErrAliasUnverified):
+			c.JSON(http.StatusForbidden, gin.H{"message": err.Error()})
+		case errors.Is(err, services.ErrAliasTaken):
+			c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to register alias"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, alias)
+}
+
+// DeleteAlias removes one of the logged-in user's aliases
+//
+//	@Summary		deleteAlias
+//	@Description	Removes an alias; payers can no longer use it
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string	true	"Alias ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/aliases/{id} [delete]
+func DeleteAlias(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid alias ID"})
+		return
+	}
+	if err := services.DeleteAlias(database.DB, userID.(uint), uint(id)); err != nil {
+		if errors.Is(err, services.ErrAliasNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Alias not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete alias"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Alias deleted"})
+}
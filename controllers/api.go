@@ -1,86 +1,78 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"gotestbackend/database"
 	"gotestbackend/middlewares"
 	"gotestbackend/models"
+	"gotestbackend/services"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // @Summary		Register a new user
-// @Description	Registers a new user with initial credit
+// @Description	Registers a new user. The account opens empty; running sign-up promotions are paid once the user verifies an email or phone. The account number is assigned by the system
 // @Tags			Auth , CRUD
 // @Security		BearerAuth
 // @Accept			json
 // @Produce		json
-// @Param			user	body		models.User	true	"User data"
+// @Param			user	body		RegisterPayload	true	"User data"
 // @Success		201		{object}	models.User
 // @Failure		400		{object}	map[string]string	"message"
 // @Failure		401		{object}	map[string]string	"message"
 // @Router			/user/register [post]
 func Register(c *gin.Context) {
-	var newUser models.User
+	var payload RegisterPayload
 	//fmt.Println("passhash :", string(hashedPassword))
-	if err := c.ShouldBindJSON(&newUser); err != nil {
+	if err := c.ShouldBindJSON(&payload); err != nil {
 		//c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		c.JSON(http.StatusNotFound, gin.H{"Message": "Invalid input"})
 		return
 	}
-	// Validate AccountNumber
-	if !isValidAccountNumber(newUser.AccountNumber) {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Account Number must be a 10-digit number"})
-		return
-	}
+	newUser := models.User{Username: payload.Username, Password: payload.Password, FirstName: payload.FirstName, LastName: payload.LastName}
 	var userexists models.User
-	database.DB.Where("username = ? ", newUser.Username).First(&userexists)
+	// Unscoped so a soft-deleted user's username is not handed to someone else
+	database.DB.Unscoped().Where("username = ? ", newUser.Username).First(&userexists)
 	if userexists.ID > 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"Message": "User exist"})
 		return
 	}
-	database.DB.Where("account_number = ? ", newUser.AccountNumber).First(&userexists)
-	if userexists.ID > 0 {
-		c.JSON(http.StatusNotFound, gin.H{"Message": "Account Number exist"})
+	//fmt.Println("pass :", newUser.Password)
+	hashedPassword, err := services.HashPassword(newUser.Password, newUser)
+	if err != nil {
+		respondPasswordError(c, err)
 		return
 	}
-	//fmt.Println("pass :", newUser.Password)
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(newUser.Password), bcrypt.DefaultCost)
-	newUser.Password = string(hashedPassword)
+	newUser.Password = hashedPassword
 	//fmt.Println("pass hashedPassword:", newUser.Password)
-	// Simulate credit addition
-	newUser.Credit = 1000.0
-	// Save user to database
-	database.DB.Create(&newUser)
-
-	c.JSON(http.StatusCreated, newUser)
-}
-
-// isValidAccountNumber validates if the account number is a 10-digit number
-func isValidAccountNumber(accountNumber string) bool {
-	if len(accountNumber) != 10 {
-		return false
-	}
-	for _, r := range accountNumber {
-		if r < '0' || r > '9' {
-			return false
-		}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return services.OpenCustomerAccount(tx, &newUser)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create user"})
+		return
 	}
-	return true
+	// Sign-up promotions are granted once the user verifies a contact
+	c.JSON(http.StatusCreated, newUser)
 }
 
 // @Summary		Get All User
-// @Description	Get details all user
-// @Tags			CRUD
+// @Description	Get details all user; admin only
+// @Tags			admin
 // @Security		BearerAuth
 // @Accept			json
 // @Produce		json
 // @Success		200	{object}	[]models.User
+// @Failure		401	{object}	map[string]string	"message"
+// @Failure		403	{object}	map[string]string	"message"
 // @Failure		404	{object}	map[string]string	"message"
-// @Router			/userAll [get]
+// @Router			/admin/users [get]
 func GetAllUser(c *gin.Context) {
 	var user []models.User
 	if err := database.DB.Find(&user).Error; err != nil {
@@ -93,120 +85,176 @@ func GetAllUser(c *gin.Context) {
 // GetUser retrieves the logged-in user's details
 //
 //	@Summary		Get user by ID
-//	@Description	Get details of a user by their ID
-//	@Tags			CRUD
+//	@Description	Get details of a user by their ID; admin only
+//	@Tags			admin
 //	@Security		BearerAuth
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		string	true	"User ID"
 //	@Success		200	{object}	models.User
+//	@Failure		401	{object}	models.ErrorResponse
+//	@Failure		403	{object}	models.ErrorResponse
 //	@Failure		404	{object}	models.ErrorResponse
-//	@Router			/user/GetUserByID/{id} [get]
+//	@Router			/admin/users/{id} [get]
 func GetUserByID(c *gin.Context) {
-	id := c.Param("id")
-	var user models.User
-	if err := database.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{Message: "User not found"})
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	user, err := GetDataUser(id)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
 		return
 	}
 	c.JSON(http.StatusOK, user)
 }
 
 // @Summary		Update User By ID
-// @Description	Update a user
-// @Tags			CRUD
+// @Description	Update a user's profile; admin only
+// @Tags			admin
 // @Security		BearerAuth
 // @Accept			json
 // @Produce		json
 //
 // @Param			id		path		string		true	"User ID"
 //
-// @Param			user	body		models.User	true	"User data"
+// @Param			user	body		AdminUpdateUserPayload	true	"User data"
 // @Success		201		{object}	models.User
 // @Failure		400		{object}	map[string]string	"message"
+// @Failure		401		{object}	map[string]string	"message"
+// @Failure		403		{object}	map[string]string	"message"
 // @Failure		404		{object}	map[string]string	"message"
-// @Router			/user/UpdateUserByID/{id} [put]
+// @Failure		500		{object}	map[string]string	"message"
+// @Router			/admin/users/{id} [put]
 func UpdateUserByID(c *gin.Context) {
-	id := c.Param("id")
-	var user models.User
-	if err := database.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+	id, ok := parseUserID(c)
+	if !ok {
 		return
 	}
-	var updatedUser models.User
-	if err := c.ShouldBindJSON(&updatedUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	user, err := GetDataUser(id)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
 		return
 	}
-	var userexists models.User
-	database.DB.Where("username = ? ", updatedUser.Username).First(&userexists)
-	if userexists.ID > 0 {
-		c.JSON(http.StatusUnauthorized, gin.H{"Message": "User exist"})
+	// House accounts are managed by the ledger, not edited by hand
+	if user.Role == models.RoleSystem {
+		c.JSON(http.StatusForbidden, gin.H{"message": "System accounts cannot be updated"})
 		return
 	}
-	if !isValidAccountNumber(updatedUser.AccountNumber) {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Account Number must be a 10-digit number"})
+	before := user
+	var payload AdminUpdateUserPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
-	database.DB.Where("account_number = ? ", updatedUser.AccountNumber).First(&userexists)
-	if userexists.ID > 0 {
-		c.JSON(http.StatusNotFound, gin.H{"Message": "Account Number exist"})
+	// Empty names are left as they are
+	if err := database.DB.Model(&user).Updates(models.User{FirstName: payload.FirstName, LastName: payload.LastName}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update user"})
 		return
 	}
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(updatedUser.Password), bcrypt.DefaultCost)
-	updatedUser.Password = string(hashedPassword)
-	// Update user fields
-	database.DB.Model(&user).Updates(updatedUser)
+	recordAudit(c, services.AuditProfileUpdate, "user", fmt.Sprint(user.ID), services.AuditDiff(before, user))
 	c.JSON(http.StatusOK, user)
 }
 
-// @Summary		Update User By ID
-// @Description	Update a  user
-// @Tags			CRUD
+// @Summary		Delete User By ID
+// @Description	Soft-delete a user; admin only
+// @Tags			admin
 // @Security		BearerAuth
 // @Accept			json
 // @Produce		json
 // @Param			id	path		string				true	"User ID"
 // @Success		201	{object}	map[string]string	"message"
 // @Failure		400	{object}	map[string]string	"message"
-// @Router			/user/DeleteUserByID/{id} [delete]
+// @Failure		401	{object}	map[string]string	"message"
+// @Failure		403	{object}	map[string]string	"message"
+// @Failure		404	{object}	map[string]string	"message"
+// @Failure		409	{object}	map[string]string	"message"
+// @Failure		500	{object}	map[string]string	"message"
+// @Router			/admin/users/{id} [delete]
 func DeleteUserByID(c *gin.Context) {
-	id := c.Param("id")
-	var user models.User
-	if err := database.DB.First(&user, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	user, err := GetDataUser(id)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
 		return
 	}
-	// Delete user
-	database.DB.Delete(&user)
+	if user.Role == models.RoleSystem {
+		c.JSON(http.StatusForbidden, gin.H{"message": "System accounts cannot be deleted"})
+		return
+	}
+	// Money must not disappear with the account
+	if user.Credit != 0 || user.HeldAmount != 0 {
+		c.JSON(http.StatusConflict, gin.H{"message": "Balance and pending withdrawals must be settled before the user can be deleted"})
+		return
+	}
+	// Soft delete user, transactions keep pointing at the row
+	if err := database.DB.Delete(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete user"})
+		return
+	}
+	recordAudit(c, services.AuditUserDelete, "user", fmt.Sprint(user.ID), map[string]interface{}{
+		"username":       user.Username,
+		"account_number": user.AccountNumber,
+	})
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// AdminUpdateUserPayload is what an admin may change on a user's profile.
+// Everything else has its own endpoint or the ledger: credit only changes
+// through postings such as an approved balance adjustment, status and deletion
+// through their endpoints with their checks, passwords through PATCH /user/me
+// or a reset token, and account numbers are issued once and never reassigned.
+type AdminUpdateUserPayload struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// RegisterPayload is used to bind the sign-up request body. Credit, role and
+// account number are not taken from the client.
+type RegisterPayload struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
 // LoginPayload is used to bind login request body
 type LoginPayload struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// DeviceLabel names the session, e.g. "Pixel 8"; optional
+	DeviceLabel string `json:"device_label"`
 }
 
 // UpdateUserPayload is used to bind update request body
 type UpdateUserPayload struct {
-	FirstName     string `json:"first_name"`
-	LastName      string `json:"last_name"`
-	Password      string `json:"password"`
-	AccountNumber string `json:"account_number"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Password  string `json:"password"`
+	// CurrentPassword is required to set a new Password
+	CurrentPassword string `json:"current_password"`
+	AccountNumber   string `json:"account_number"`
+	// Email and Phone are sent a verification code when changed
+	Email string `json:"email"`
+	Phone string `json:"phone"`
 }
 
 // Login godoc
 //
 //	@Summary		login
-//	@Description	Authenticates a user and returns a JWT token
+//	@Description	Authenticates a user and returns a JWT token. Users with two-factor authentication get an MFA challenge token instead, to exchange at /user/login/mfa
 //	@Tags			Auth
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		LoginPayload		true	"Login payload"
-//	@Success		200		{object}	map[string]string	"token"
+//	@Success		200		{object}	map[string]string	"token, or an MFAChallengeResponse"
 //	@Failure		400		{object}	map[string]string	"message"
 //	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"code PASSWORD_RESET_REQUIRED with a reset_token"
+//	@Failure		429		{object}	map[string]string	"message"
 //	@Failure		500		{object}	map[string]string	"message"
 //	@Router			/user/login [post]
 func Login(c *gin.Context) {
@@ -217,39 +265,100 @@ func Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
+	if !checkLoginThrottle(c, payload.Username) {
+		return
+	}
 	database.DB.Where("username =?", payload.Username).First(&user)
-	if user.ID == 0 {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "user dose not exists"})
+	// Unknown users are checked against a dummy hash so the response takes as
+	// long as for a wrong password and does not reveal which usernames exist
+	hash := dummyPasswordHash
+	if user.ID != 0 && user.Role != models.RoleSystem {
+		hash = []byte(user.Password)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(payload.Password)); err != nil || user.ID == 0 || user.Role == models.RoleSystem {
+		failLogin(c, payload.Username)
+		return
+	}
+	//fmt.Println("User ID = ", user.ID)
+	if user.PasswordResetRequired {
+		// The password was handed out, e.g. to bootstrap the first admin; it only buys a reset
+		token, err := services.IssuePasswordResetToken(database.DB, user.ID, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not issue reset token"})
+			return
+		}
+		services.RecordLoginSuccess(database.DB, user.Username)
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":        codePasswordResetRequired,
+			"message":     "Password must be reset before logging in; use reset_token with /user/password/reset",
+			"reset_token": token,
+		})
+		return
+	}
+	mfaEnabled, err := services.MFAEnabled(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not check two-factor authentication"})
+		return
+	}
+	if mfaEnabled {
+		// The session is only issued once the second factor is checked by LoginMFA
+		challenge, expiresAt, err := middlewares.GenerateMFAChallengeToken(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, MFAChallengeResponse{MFARequired: true, MFAToken: challenge, ExpiresAt: expiresAt})
 		return
 	}
+	issueSession(c, user, payload.DeviceLabel, false)
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid credentials"})
+// issueSession records the login as a new session and returns its JWT.
+// mfaVerified tells whether the login went through the second factor.
+func issueSession(c *gin.Context, user models.User, deviceLabel string, mfaVerified bool) {
+	services.RecordLoginSuccess(database.DB, user.Username)
+	now := time.Now()
+	database.DB.Model(&user).Update("last_activity_at", now)
+	session, err := services.CreateSession(database.DB, user.ID, deviceLabel, c.ClientIP(), c.Request.UserAgent(), now.Add(middlewares.SessionTTL))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create session"})
 		return
 	}
-	//fmt.Println("User ID = ", user.ID)
-	token, err := middlewares.GenerateToken(user.ID)
+	token, err := middlewares.GenerateToken(user.ID, session.ID, session.ExpiresAt, mfaVerified)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate token"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"token": token}) //token
+	// Not logged in yet, so the actor is set explicitly
+	c.Set("user_id", user.ID)
+	recordAudit(c, services.AuditLoginSuccess, "user", fmt.Sprint(user.ID), map[string]interface{}{
+		"session_id":   session.ID,
+		"mfa_verified": mfaVerified,
+	})
+	response := gin.H{"token": token}
+	if user.Role == models.RoleAdmin && !mfaVerified {
+		// Admin routes stay closed until an authenticator is confirmed
+		response["mfa_enrolment_required"] = true
+	}
+	c.JSON(http.StatusOK, response) //token
 }
 
 // GetUser retrieves the logged-in user's details
 //
 //	@Summary		getUser
-//	@Description	GetUserProfile by id token
+//	@Description	GetUserProfile by id token, or by an API key with read:profile
 //	@Tags			User
 //	@Security		BearerAuth
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{object}	models.User
+//	@Success		200	{object}	UserProfile
 //	@Failure		400	{object}	models.ErrorResponse
 //	@Failure		404	{object}	models.ErrorResponse
 //	@Router			/user/me [get]
 func GetUser(c *gin.Context) {
-	var user models.User
+	if !requireScope(c, models.ScopeReadProfile) {
+		return
+	}
 	idparam, exists := c.Get("user_id")
 	//fmt.Println("user_id ", idparam)
 	if !exists {
@@ -262,17 +371,35 @@ func GetUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "User ID type assertion failed"})
 		return
 	}
-	if err := database.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{Message: "User not found"})
+	user, err := GetDataUser(userID)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Message: "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Message: "Failed to load user"})
 		return
 	}
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, UserProfile{
+		User:               user,
+		OverdraftUsed:      services.OverdraftUsed(user),
+		OverdraftAvailable: services.OverdraftAvailable(user),
+		AvailableBalance:   services.AvailableBalance(user),
+	})
+}
+
+// UserProfile is the logged-in user's details with their overdraft position
+type UserProfile struct {
+	models.User
+	OverdraftUsed      float64 `json:"overdraft_used"`
+	OverdraftAvailable float64 `json:"overdraft_available"`
+	AvailableBalance   float64 `json:"available_balance"`
 }
 
 // UpdateUser updates the logged-in user's details
 //
 //	@Summary		updateUser
-//	@Description	UpdateUser by id token
+//	@Description	UpdateUser by id token. A new password needs current_password. A changed email or phone is unverified until the code sent to it is confirmed
 //	@Tags			User
 //	@Security		BearerAuth
 //	@Accept			json
@@ -282,6 +409,9 @@ func GetUser(c *gin.Context) {
 //	@Failure		400		{object}	map[string]string	"message"
 //	@Failure		401		{object}	map[string]string	"message"
 //	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		429		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
 //	@Router			/user/me [patch]
 func UpdateUser(c *gin.Context) {
 	userId, exists := c.Get("user_id")
@@ -294,61 +424,117 @@ func UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
-	var user models.User
-	if err := database.DB.First(&user, userId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+	userID, ok := userId.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Invalid user ID"})
+		return
+	}
+	user, err := GetDataUser(userID)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
 		return
 	}
+	before := user
 	if payload.FirstName != "" {
 		user.FirstName = payload.FirstName
 	}
 	if payload.LastName != "" {
 		user.LastName = payload.LastName
 	}
-	if payload.AccountNumber != "" {
-		// Validate AccountNumber
-		if !isValidAccountNumber(payload.AccountNumber) {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Account Number must be a 10-digit number"})
+	if payload.AccountNumber != "" && payload.AccountNumber != user.AccountNumber {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Account Number is assigned by the system and cannot be changed"})
+		return
+	}
+	if payload.Password != "" {
+		// A stolen session must not be enough to take over the account
+		if !checkLoginThrottle(c, user.Username) {
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.CurrentPassword)); err != nil {
+			if !recordFailedAttempt(c, user.Username, "change_password") {
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Current password is incorrect"})
 			return
 		}
-		var userexists models.User
-		database.DB.Where("account_number = ? ", payload.AccountNumber).First(&userexists)
-		if userexists.ID > 0 {
-			c.JSON(http.StatusNotFound, gin.H{"Message": "Account Number exist"})
+		hashedPassword, err := services.HashPassword(payload.Password, user)
+		if err != nil {
+			respondPasswordError(c, err)
 			return
 		}
-		user.AccountNumber = payload.AccountNumber
+		// Changing the password signs out every existing session
+		now := time.Now()
+		user.Password = hashedPassword
+		user.PasswordChangedAt = &now
+	}
+	contacts := map[string]string{models.ContactEmail: payload.Email, models.ContactPhone: payload.Phone}
+	for channel, value := range contacts {
+		if value == "" {
+			continue
+		}
+		if _, err := services.NormaliseContact(channel, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update user"})
+		return
 	}
 	if payload.Password != "" {
-		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
-		user.Password = string(hashedPassword)
+		services.RevokeSessions(database.DB, user.ID, 0)
+	}
+	for _, channel := range []string{models.ContactEmail, models.ContactPhone} {
+		if contacts[channel] == "" {
+			continue
+		}
+		if err := services.ChangeContact(database.DB, &user, channel, contacts[channel]); err != nil {
+			recordAudit(c, services.AuditProfileUpdate, "user", fmt.Sprint(user.ID), services.AuditDiff(before, user))
+			respondContactError(c, err)
+			return
+		}
 	}
-	database.DB.Save(&user)
+	recordAudit(c, services.AuditProfileUpdate, "user", fmt.Sprint(user.ID), services.AuditDiff(before, user))
 	c.JSON(http.StatusOK, user)
 }
 
 type transferRequest struct {
 	//ID uint `json:"id"`
 	//SenderAccount   string  `json:"sender_account"`
-	ReceiverAccount string  `json:"receiver_account"`
-	Amount          float64 `json:"amount"`
+	ReceiverAccount string `json:"receiver_account"`
+	// AliasType and AliasValue pay by phone, email or username instead of ReceiverAccount
+	AliasType  string  `json:"alias_type"`
+	AliasValue string  `json:"alias_value"`
+	Amount     float64 `json:"amount"`
+	// QuoteToken is optional and comes from /accounting/transfer/inquiry
+	QuoteToken string `json:"quote_token"`
+	// PIN is the transaction PIN, required from services.PINThreshold or when fraud rules challenge
+	PIN string `json:"pin"`
 }
 
 // TransferCredit transfers credit from one user to another
 //
 //	@Summary		transfer
-//	@Description	TransferCredit transfers credit from one user to another
+//	@Description	TransferCredit transfers credit from one user to another. Any fee is charged on top of the amount and posted as a separate fee line. The transaction PIN is required for large or challenged transfers. API keys need write:transfers
 //	@Tags			accounting
 //	@Security		BearerAuth
 //	@Accept			json
 //	@Produce		json
 //	@Param			transferRequest	body		transferRequest	true	"transferRequest data"
 //	@Success		200				{object}	models.Transaction
+//	@Success		202				{object}	map[string]interface{}	"held for fraud review"
 //	@Failure		400				{object}	map[string]string	"message"
+//	@Failure		403				{object}	map[string]string	"code and message"
 //	@Failure		404				{object}	map[string]string	"message"
+//	@Failure		409				{object}	map[string]string	"code and message"
+//	@Failure		422				{object}	map[string]string	"code and message"
+//	@Failure		423				{object}	map[string]string	"code and message"
 //	@Failure		500				{object}	map[string]string	"message"
 //	@Router			/accounting/transfer [post]
 func Transfer(c *gin.Context) {
+	if !requireScope(c, models.ScopeWriteTransfers) {
+		return
+	}
 	idparam, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "User not logged in"})
@@ -370,69 +556,231 @@ func Transfer(c *gin.Context) {
 	// Check if sender and receiver IDs are valid
 	sender, err := GetDataUser(uint(userID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "Sender not found"})
+		respondUserLookupError(c, err, "Sender not found")
+		return
+	}
+	receiverAccount, ok := resolveReceiverAccount(c, transferRequest.ReceiverAccount, transferRequest.AliasType, transferRequest.AliasValue)
+	if !ok {
 		return
 	}
+	transferRequest.ReceiverAccount = receiverAccount
 	receiver, err := GetDataUserByAccount(transferRequest.ReceiverAccount)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "Receiver not found"})
+		respondUserLookupError(c, err, "Receiver not found")
 		return
 	}
-	// Update sender and receiver credits in database
-	// Validate if sender has enough credit
-	if sender.Credit < transferRequest.Amount {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Insufficient credit"})
-		return
+	// A quote must match the transfer it was issued for
+	var quote *middlewares.QuoteClaims
+	if transferRequest.QuoteToken != "" {
+		quote, err = middlewares.ParseQuoteToken(transferRequest.QuoteToken)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid or expired quote"})
+			return
+		}
+		if quote.UserID != sender.ID || quote.ReceiverAccount != transferRequest.ReceiverAccount || quote.Amount != transferRequest.Amount {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Quote does not match transfer"})
+			return
+		}
 	}
-	// Perform credit transfer
-	// db.Model(&sender).Update("credit", sender.Credit - amount)
-	sender.Credit -= transferRequest.Amount
-	// db.Model(&receiver).Update("credit", receiver.Credit + amount)
-	receiver.Credit += transferRequest.Amount
-	// Update sender and receiver in database
-	err = database.DB.Save(&sender).Error
+	fee, err := services.TransferFee(database.DB, sender, transferRequest.Amount, time.Now())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update sender"})
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate fee"})
+		return
+	}
+	if terr := checkTransferRules(sender, receiver, transferRequest.Amount, fee); terr != nil {
+		respondTransferError(c, terr)
 		return
 	}
-	err = database.DB.Save(&receiver).Error
+	// Fraud rules may hold the transfer for review or block it outright. This
+	// first look only decides whether to ask for the PIN; executeTransfer
+	// takes the decision that counts under the account locks.
+	assessment, err := services.AssessTransfer(database.DB, sender, receiver, transferRequest.Amount, time.Now())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update receiver"})
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to assess transfer"})
 		return
 	}
-	// Record transaction
-	transaction := models.Transaction{
-		SenderID:          sender.ID,
-		SenderRemaining:   sender.Credit,
-		ReceiverID:        receiver.ID,
-		ReceiverRemaining: receiver.Credit,
-		Amount:            transferRequest.Amount,
-		CreatedAt:         time.Now(),
-		UpdatedAt:         time.Now(),
+	// Large transfers need the transaction PIN. A fraud challenge is cleared by
+	// the PIN too; users who have not set one go to review instead.
+	needsPIN := transferRequest.Amount >= services.PINThreshold
+	if assessment.Decision == services.FraudChallenge && !needsPIN {
+		needsPIN, err = services.HasTransactionPIN(database.DB, sender.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load PIN"})
+			return
+		}
+	}
+	gate := &fraudGate{}
+	if needsPIN {
+		if terr := checkTransferPIN(sender.ID, transferRequest.PIN); terr != nil {
+			respondTransferError(c, terr)
+			return
+		}
+		gate.PINVerified = true
 	}
-	err = database.DB.Create(&transaction).Error
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to record transaction"})
+	transaction, terr := executeTransfer(sender, receiver, transferRequest.Amount, quote, gate)
+	if terr != nil {
+		respondTransferError(c, terr)
 		return
 	}
+	if gate.Review != nil {
+		respondFraudDecision(c, gate.Assessment, *gate.Review)
+		return
+	}
+	recordAudit(c, services.AuditTransfer, "transaction", fmt.Sprint(transaction.ID), map[string]interface{}{
+		"sender_id":   sender.ID,
+		"receiver_id": receiver.ID,
+		"amount":      transaction.Amount,
+	})
 	c.JSON(http.StatusOK, transaction)
 }
 
-func GetDataUser(user_id uint) (models.User, error) {
-	var user models.User
-	if err := database.DB.First(&user, user_id).Error; err != nil {
-		return user, nil
+// fraudGate asks executeTransfer to run the fraud rules under the account
+// locks, so concurrent transfers cannot each slip under a velocity limit.
+// PINVerified clears a challenge. A transfer the rules stop is queued for
+// review instead of posted, and Assessment and Review are filled in.
+type fraudGate struct {
+	PINVerified bool
+	Assessment  services.FraudAssessment
+	Review      *models.FraudReview
+}
+
+// executeTransfer moves amount plus any fee from sender to receiver. Sender,
+// receiver and fee account credits change in one database transaction, so a
+// failure part-way leaves every balance untouched. A quote, if given, must
+// still match the fee and is redeemed in the same transaction. gate is nil
+// when the fraud rules were already settled, e.g. by an approved review.
+func executeTransfer(sender, receiver models.User, amount float64, quote *middlewares.QuoteClaims, gate *fraudGate) (models.Transaction, *transferError) {
+	var transaction models.Transaction
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// The house fee account is only needed when there is a fee to collect. It
+		// is resolved up front so every account is locked in one ID-ordered call.
+		fee, err := services.TransferFee(tx, sender, amount, time.Now())
+		if err != nil {
+			return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to calculate fee"}
+		}
+		accounts := []*models.User{&sender, &receiver}
+		var house *models.User
+		if fee > 0 {
+			account, err := services.SystemAccount(tx, models.SystemAccountFees)
+			if err != nil {
+				return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Fee account not configured"}
+			}
+			house = &account
+			accounts = append(accounts, house)
+		}
+		// Re-read the rows under lock so concurrent transfers see the latest credit
+		if err := services.LockUsers(tx, accounts...); err != nil {
+			return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to lock accounts"}
+		}
+		// The fee is final only once the sender is locked
+		if fee, err = services.TransferFee(tx, sender, amount, time.Now()); err != nil {
+			return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to calculate fee"}
+		}
+		if fee > 0 && house == nil {
+			return &transferError{Status: http.StatusConflict, Code: codeTransferFailed, Message: "The fee changed while the transfer was made, try again"}
+		}
+		if terr := checkTransferRules(sender, receiver, amount, fee); terr != nil {
+			return terr
+		}
+		if quote != nil {
+			if fee != quote.Fee || amount+fee != quote.Total {
+				return &transferError{Status: http.StatusConflict, Code: codeQuoteChanged, Message: "The fee has changed since the quote, request a new one"}
+			}
+			if err := services.RedeemQuote(tx, quote.Id, time.Unix(quote.ExpiresAt, 0)); err != nil {
+				return quoteRedeemError(err)
+			}
+		}
+		if gate != nil {
+			assessment, err := services.AssessTransfer(tx, sender, receiver, amount, time.Now())
+			if err != nil {
+				return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to assess transfer"}
+			}
+			if assessment.Decision == services.FraudChallenge && gate.PINVerified {
+				assessment.Decision = services.FraudAllow
+			}
+			if assessment.Decision != services.FraudAllow {
+				// The held transfer uses up any quote; approval re-checks the fee
+				review, err := services.QueueFraudReview(tx, sender, receiver, amount, assessment)
+				if err != nil {
+					return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to queue transfer for review"}
+				}
+				gate.Assessment, gate.Review = assessment, &review
+				return nil
+			}
+		}
+		// Perform credit transfer
+		transaction, err = services.PostLedger(tx, &sender, &receiver, amount, models.Transaction{
+			Kind: models.TransactionTransfer,
+			Fee:  fee,
+		})
+		if err != nil {
+			return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to record transaction"}
+		}
+		// The fee is its own ledger line to the house fee account
+		if fee > 0 {
+			if _, err := services.PostLedger(tx, &sender, house, fee, models.Transaction{
+				Kind:     models.TransactionFee,
+				ParentID: &transaction.ID,
+				Memo:     "Transfer fee",
+			}); err != nil {
+				return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to record fee"}
+			}
+		}
+		if err := tx.Model(&sender).Update("last_activity_at", time.Now()).Error; err != nil {
+			return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to update sender"}
+		}
+		return nil
+	})
+	if err != nil {
+		var terr *transferError
+		if errors.As(err, &terr) {
+			return models.Transaction{}, terr
+		}
+		return models.Transaction{}, &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Transfer failed"}
 	}
-	return user, nil
+	return transaction, nil
+}
+
+// quoteRedeemError maps a services.RedeemQuote failure onto a transfer error
+func quoteRedeemError(err error) *transferError {
+	if errors.Is(err, services.ErrQuoteRedeemed) {
+		return &transferError{Status: http.StatusConflict, Code: codeQuoteUsed, Message: "Quote has already been used"}
+	}
+	return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to redeem quote"}
+}
+
+// GetDataUser loads a user by ID, returning services.ErrUserNotFound or services.ErrUserLookup on failure
+func GetDataUser(user_id uint) (models.User, error) {
+	return services.GetUserByID(database.DB, user_id)
 }
+
+// GetDataUserByAccount loads a user by account number, returning services.ErrUserNotFound or services.ErrUserLookup on failure
 func GetDataUserByAccount(account_number string) (models.User, error) {
-	var user models.User
-	if err := database.DB.Where("account_number = ?", account_number).First(&user).Error; err != nil {
-		return user, nil
+	return services.GetUserByAccount(database.DB, account_number)
+}
+
+// respondUserLookupError writes 400 for a mistyped account number, 404 for a
+// missing user and 500 for a database failure
+func respondUserLookupError(c *gin.Context, err error, notFoundMessage string) {
+	if errors.Is(err, services.ErrInvalidAccountNumber) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Account number is not valid, check the last digit"})
+		return
+	}
+	if errors.Is(err, services.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": notFoundMessage})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load user"})
+}
+
+// parseUserID reads the :id path parameter
+func parseUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
+		return 0, false
 	}
-	//fmt.Println("GetDataUserByAccount account_number : ", account_number)
-	//fmt.Println("GetDataUserByAccount user : ", user.ID)
-	return user, nil
+	return uint(id), true
 }
 
 // TransferListRequest defines the query parameters for transfer list API
@@ -441,10 +789,10 @@ type TransferListRequest struct {
 	EndDate   string `json:"end_date" form:"end_date"`
 }
 
-// GetTransferList retrieves the list of credit transfer history with optional filters
+// GetTransferList retrieves the list of credit transfer history with optional filters. API keys need read:transfers
 //
 //	@Summary		getTransferList
-//	@Description	GetTransferList retrieves the list of credit transfer history with optional filters
+//	@Description	GetTransferList retrieves the list of credit transfer history with optional filters. API keys need read:transfers
 //	@Tags			accounting
 //	@Security		BearerAuth
 //	@Produce		json
@@ -458,6 +806,9 @@ type TransferListRequest struct {
 //	@Failure		500					{object}	map[string]string	"message"
 //	@Router			/accounting/transfer-list [get]
 func GetTransferList(c *gin.Context) {
+	if !requireScope(c, models.ScopeReadTransfers) {
+		return
+	}
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "User not authenticated"})
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// postTransfer sends body to Transfer as sender and returns the response
+func postTransfer(t *testing.T, sender models.User, body map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	r := gin.New()
+	r.POST("/accounting/transfer", asUser(sender.ID, Transfer)...)
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/accounting/transfer", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func countTransactions(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&models.Transaction{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestTransferToUnknownAccountChangesNothing(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.CreateUser(t, db, "sender", "123456789", 500)
+	before := countTransactions(t, db)
+
+	unknown := "987654321" + string(utils.LuhnCheckDigit("987654321"))
+	w := postTransfer(t, sender, map[string]interface{}{"receiver_account": unknown, "amount": 100})
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404; body %s", w.Code, w.Body)
+	}
+	if got := testutil.Credit(t, db, sender.ID); got != 500 {
+		t.Errorf("sender credit = %v, want 500", got)
+	}
+	if got := countTransactions(t, db); got != before {
+		t.Errorf("transactions = %d, want %d", got, before)
+	}
+}
+
+func TestTransferToMistypedAccountIsRejected(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.CreateUser(t, db, "sender", "123456789", 500)
+	receiver := testutil.CreateUser(t, db, "receiver", "234567891", 0)
+	// Change the check digit so the number no longer validates
+	last := receiver.AccountNumber[9]
+	mistyped := receiver.AccountNumber[:9] + string('0'+(last-'0'+1)%10)
+
+	w := postTransfer(t, sender, map[string]interface{}{"receiver_account": mistyped, "amount": 100})
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400; body %s", w.Code, w.Body)
+	}
+	if got := testutil.Credit(t, db, receiver.ID); got != 0 {
+		t.Errorf("receiver credit = %v, want 0", got)
+	}
+}
+
+func TestTransferMovesCredit(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.CreateUser(t, db, "sender", "123456789", 500)
+	receiver := testutil.CreateUser(t, db, "receiver", "234567891", 0)
+	before := countTransactions(t, db)
+
+	w := postTransfer(t, sender, map[string]interface{}{"receiver_account": receiver.AccountNumber, "amount": 100})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", w.Code, w.Body)
+	}
+	if got := testutil.Credit(t, db, sender.ID); got != 400 {
+		t.Errorf("sender credit = %v, want 400", got)
+	}
+	if got := testutil.Credit(t, db, receiver.ID); got != 100 {
+		t.Errorf("receiver credit = %v, want 100", got)
+	}
+	if got := countTransactions(t, db); got != before+1 {
+		t.Errorf("transactions = %d, want %d", got, before+1)
+	}
+}
+
+func TestTransferCollectsFeeIntoHouseAccount(t *testing.T) {
+	db := testutil.NewDB(t)
+	if err := db.Create(&models.FeeSchedule{Name: "Flat", AccountType: models.AccountTypePersonal, Kind: models.FeeFlat, FlatAmount: 5, Active: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var house models.User
+	if err := db.Where("username = ?", models.SystemAccountFees).First(&house).Error; err != nil {
+		t.Fatal(err)
+	}
+	sender := testutil.CreateUser(t, db, "sender", "123456789", 500)
+	receiver := testutil.CreateUser(t, db, "receiver", "234567891", 0)
+
+	w := postTransfer(t, sender, map[string]interface{}{"receiver_account": receiver.AccountNumber, "amount": 100})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", w.Code, w.Body)
+	}
+	if got := testutil.Credit(t, db, sender.ID); got != 395 {
+		t.Errorf("sender credit = %v, want 395", got)
+	}
+	if got := testutil.Credit(t, db, house.ID); got != house.Credit+5 {
+		t.Errorf("fee account credit = %v, want %v", got, house.Credit+5)
+	}
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPIKeyPayload is used to bind a new API key request
+type CreateAPIKeyPayload struct {
+	Name        string     `json:"name" binding:"required"`
+	Scopes      []string   `json:"scopes" binding:"required"`
+	IPAllowlist []string   `json:"ip_allowlist"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse carries the new key. Key is not shown again.
+type CreateAPIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// requireScope answers 403 when the request came with an API key that lacks
+// scope. Login sessions may do everything their user can.
+func requireScope(c *gin.Context, scope string) bool {
+	scopes, viaAPIKey := c.Get("scopes")
+	if !viaAPIKey {
+		return true
+	}
+	for _, granted := range scopes.([]string) {
+		if granted == scope {
+			return true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"message": "API key is missing scope " + scope})
+	return false
+}
+
+// CreateAPIKey issues an API key for the logged-in user
+//
+//	@Summary		createAPIKey
+//	@Description	Issues a named API key with scopes read:profile, read:transfers and/or write:transfers, an optional expiry and IP allowlist. Send it as "Authorization: ApiKey {key}". The key is only shown once
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAPIKeyPayload	true	"Key settings"
+//	@Success		201		{object}	CreateAPIKeyResponse
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/user/me/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var payload CreateAPIKeyPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	record, key, err := services.CreateAPIKey(database.DB, userID.(uint), payload.Name, payload.Scopes, payload.IPAllowlist, payload.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKeyRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create API key"})
+		return
+	}
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: record, Key: key})
+}
+
+// CreateUserAPIKey issues an API key for another user, e.g. to set up a business integration
+//
+//	@Summary		createUserAPIKey
+//	@Description	Issues an API key on behalf of a user, with the same settings as /user/me/api-keys. The key is only shown once
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string				true	"User ID"
+//	@Param			payload	body		CreateAPIKeyPayload	true	"Key settings"
+//	@Success		201		{object}	CreateAPIKeyResponse
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/api-keys [post]
+func CreateUserAPIKey(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var payload CreateAPIKeyPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	user, err := GetDataUser(id)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	if user.Role == models.RoleSystem {
+		c.JSON(http.StatusForbidden, gin.H{"message": "System accounts cannot have API keys"})
+		return
+	}
+	record, key, err := services.CreateAPIKey(database.DB, user.ID, payload.Name, payload.Scopes, payload.IPAllowlist, payload.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKeyRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create API key"})
+		return
+	}
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: record, Key: key})
+}
+
+// GetAPIKeys lists the logged-in user's API keys
+//
+//	@Summary		getAPIKeys
+//	@Description	Lists the logged-in user's API keys, including revoked ones. Keys are identified by their prefix
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	[]models.APIKey
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/api-keys [get]
+func GetAPIKeys(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var keys []models.APIKey
+	if err := database.DB.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch API keys"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey stops one of the logged-in user's API keys from working
+//
+//	@Summary		revokeAPIKey
+//	@Description	Revokes an API key of the logged-in user
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string	true	"API key ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid API key ID"})
+		return
+	}
+	if err := services.RevokeAPIKey(database.DB, userID.(uint), uint(id)); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to revoke API key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
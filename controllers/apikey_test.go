@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/middlewares"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestAPIKeyScopeAndAllowlist(t *testing.T) {
+	profile := []string{models.ScopeReadProfile}
+	tests := []struct {
+		name      string
+		scopes    []string
+		allowlist []string
+		change    func(db *gorm.DB, key *models.APIKey) error
+		proxies   []string
+		remote    string
+		forwarded string
+		want      int
+	}{
+		{"scope granted", profile, nil, nil, nil, "198.51.100.1", "", http.StatusOK},
+		{"scope missing", []string{models.ScopeReadTransfers, models.ScopeWriteTransfers}, nil, nil, nil, "198.51.100.1", "", http.StatusForbidden},
+		{"IP in range", profile, []string{"203.0.113.0/24"}, nil, nil, "203.0.113.7", "", http.StatusOK},
+		{"exact IP", profile, []string{"198.51.100.9", "203.0.113.7"}, nil, nil, "203.0.113.7", "", http.StatusOK},
+		{"IP not listed", profile, []string{"203.0.113.0/24"}, nil, nil, "198.51.100.1", "", http.StatusUnauthorized},
+		{"forged forwarding header", profile, []string{"203.0.113.0/24"}, nil, nil, "198.51.100.1", "203.0.113.7", http.StatusUnauthorized},
+		{"forwarded by a trusted proxy", profile, []string{"203.0.113.0/24"}, nil, []string{"10.0.0.1"}, "10.0.0.1", "203.0.113.7", http.StatusOK},
+		{"expired", profile, nil, func(db *gorm.DB, key *models.APIKey) error {
+			return db.Model(key).Update("expires_at", time.Now().Add(-time.Minute)).Error
+		}, nil, "198.51.100.1", "", http.StatusUnauthorized},
+		{"revoked", profile, nil, func(db *gorm.DB, key *models.APIKey) error {
+			return services.RevokeAPIKey(db, key.UserID, key.ID)
+		}, nil, "198.51.100.1", "", http.StatusUnauthorized},
+		{"owner deleted", profile, nil, func(db *gorm.DB, key *models.APIKey) error {
+			return db.Delete(&models.User{}, key.UserID).Error
+		}, nil, "198.51.100.1", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			user := testutil.CreateUser(t, db, "alice", "123456789", 0)
+			key, raw, err := services.CreateAPIKey(db, user.ID, "test", tt.scopes, tt.allowlist, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.change != nil {
+				if err := tt.change(db, &key); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := gin.New()
+			if err := r.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			r.GET("/api/user/me", middlewares.JWTOrAPIKeyMiddleware(), GetUser)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/user/me", nil)
+			req.RemoteAddr = tt.remote + ":40000"
+			req.Header.Set("Authorization", "ApiKey "+raw)
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestCreateAPIKeyRejectsBadRequests(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.CreateUser(t, db, "alice", "123456789", 0)
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name      string
+		scopes    []string
+		allowlist []string
+		expires   *time.Time
+	}{
+		{"no scopes", nil, nil, nil},
+		{"unknown scope", []string{"admin"}, nil, nil},
+		{"bad allowlist entry", []string{models.ScopeReadProfile}, []string{"not-an-ip"}, nil},
+		{"expiry in the past", []string{models.ScopeReadProfile}, nil, &past},
+	}
+	for _, tt := range tests {
+		if _, _, err := services.CreateAPIKey(db, user.ID, "test", tt.scopes, tt.allowlist, tt.expires); !errors.Is(err, services.ErrInvalidAPIKeyRequest) {
+			t.Errorf("%s: err = %v, want ErrInvalidAPIKeyRequest", tt.name, err)
+		}
+	}
+}
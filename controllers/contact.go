@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyContactPayload is used to bind a contact verification code
+type VerifyContactPayload struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// respondContactError maps contact errors onto HTTP statuses
+func respondContactError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidContact), errors.Is(err, services.ErrNoContact), errors.Is(err, services.ErrInvalidVerificationCode):
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	case errors.Is(err, services.ErrContactTaken), errors.Is(err, services.ErrContactVerified):
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+	case errors.Is(err, services.ErrVerificationCooldown), errors.Is(err, services.ErrVerificationLocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"message": err.Error()})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update contact"})
+	}
+}
+
+// SendContactCode sends a new verification code to the logged-in user's email or phone
+//
+//	@Summary		sendContactCode
+//	@Description	Sends a one-time verification code to the user's email or phone. Earlier codes stop working
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			channel	path		string				true	"email or phone"
+//	@Success		202		{object}	map[string]string	"message"
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		429		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/user/me/contacts/{channel}/code [post]
+func SendContactCode(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	user, err := GetDataUser(userID.(uint))
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	if err := services.SendVerificationCode(database.DB, user, c.Param("channel"), time.Now()); err != nil {
+		respondContactError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification code sent"})
+}
+
+// VerifyContact confirms the logged-in user's email or phone with the code sent to it
+//
+//	@Summary		verifyContact
+//	@Description	Marks the email or phone verified when the code matches. Codes expire and stop working after too many wrong tries
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			channel	path		string					true	"email or phone"
+//	@Param			payload	body		VerifyContactPayload	true	"Code"
+//	@Success		200		{object}	models.User
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		429		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/user/me/contacts/{channel}/verify [post]
+func VerifyContact(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var payload VerifyContactPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := services.VerifyContact(database.DB, userID.(uint), c.Param("channel"), payload.Code, time.Now()); err != nil {
+		respondContactError(c, err)
+		return
+	}
+	user, err := GetDataUser(userID.(uint))
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateFeeSchedule adds a fee schedule for an account type
+//
+//	@Summary		createFeeSchedule
+//	@Description	Adds a flat, percentage or tiered fee schedule. The newest active schedule for an account type is used
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			schedule	body		models.FeeSchedule	true	"Fee schedule"
+//	@Success		201			{object}	models.FeeSchedule
+//	@Failure		400			{object}	map[string]string	"message"
+//	@Failure		403			{object}	map[string]string	"message"
+//	@Failure		500			{object}	map[string]string	"message"
+//	@Router			/admin/fee-schedules [post]
+func CreateFeeSchedule(c *gin.Context) {
+	var schedule models.FeeSchedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	schedule.ID = 0
+	schedule.Active = true
+	for i := range schedule.Tiers {
+		schedule.Tiers[i].ID = 0
+	}
+	if err := services.ValidateFeeSchedule(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save fee schedule"})
+		return
+	}
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// GetFeeSchedules lists fee schedules
+//
+//	@Summary		getFeeSchedules
+//	@Description	Lists every fee schedule with its tiers
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	[]models.FeeSchedule
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/fee-schedules [get]
+func GetFeeSchedules(c *gin.Context) {
+	var schedules []models.FeeSchedule
+	if err := database.DB.Preload("Tiers").Order("id DESC").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch fee schedules"})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// DeactivateFeeSchedule stops a fee schedule from being used
+//
+//	@Summary		deactivateFeeSchedule
+//	@Description	Deactivates a fee schedule; it is kept for history
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string				true	"Fee schedule ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/fee-schedules/{id} [delete]
+func DeactivateFeeSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid fee schedule ID"})
+		return
+	}
+	result := database.DB.Model(&models.FeeSchedule{}).Where("id = ?", id).Update("active", false)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to deactivate fee schedule"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if database.DB.Model(&models.FeeSchedule{}).Where("id = ?", id).Count(&count); count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Fee schedule not found"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Fee schedule deactivated"})
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	codeFraudBlocked = "FRAUD_BLOCKED"
+	codeFraudReview  = "FRAUD_REVIEW"
+)
+
+// ReviewDecisionPayload is used to bind an admin's note on a fraud review
+type ReviewDecisionPayload struct {
+	Note string `json:"note"`
+}
+
+// respondFraudDecision tells the sender their transfer was held or blocked
+func respondFraudDecision(c *gin.Context, assessment services.FraudAssessment, review models.FraudReview) {
+	if assessment.Decision == services.FraudBlock {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":      codeFraudBlocked,
+			"message":   "Transfer blocked by fraud rules",
+			"review_id": review.ID,
+		})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"code":      codeFraudReview,
+		"message":   "Transfer held for review",
+		"review_id": review.ID,
+	})
+}
+
+// parseReviewID reads the :id path parameter of a fraud review
+func parseReviewID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid review ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// GetFraudReviews lists transfers flagged by fraud rules
+//
+//	@Summary		getFraudReviews
+//	@Description	Lists flagged transfers, newest first. Filter by status pending, approved or rejected
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			status	query		string	false	"Review status"
+//	@Success		200		{object}	[]models.FraudReview
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/fraud/reviews [get]
+func GetFraudReviews(c *gin.Context) {
+	query := database.DB.Order("id DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var reviews []models.FraudReview
+	if err := query.Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch reviews"})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// ApproveFraudReview releases a held transfer and executes it
+//
+//	@Summary		approveFraudReview
+//	@Description	Approves a flagged transfer and executes it. Balance and status rules still apply. Admins cannot decide their own transfers
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"Review ID"
+//	@Param			payload	body		ReviewDecisionPayload	false	"Note"
+//	@Success		200		{object}	models.FraudReview
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/fraud/reviews/{id}/approve [post]
+func ApproveFraudReview(c *gin.Context) {
+	decideFraudReview(c, models.ReviewApproved)
+}
+
+// RejectFraudReview discards a held transfer
+//
+//	@Summary		rejectFraudReview
+//	@Description	Rejects a flagged transfer; no money moves. Admins cannot decide their own transfers
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"Review ID"
+//	@Param			payload	body		ReviewDecisionPayload	false	"Note"
+//	@Success		200		{object}	models.FraudReview
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Router			/admin/fraud/reviews/{id}/reject [post]
+func RejectFraudReview(c *gin.Context) {
+	decideFraudReview(c, models.ReviewRejected)
+}
+
+// decideFraudReview claims a pending review and, when approving, executes the transfer
+func decideFraudReview(c *gin.Context, status string) {
+	id, ok := parseReviewID(c)
+	if !ok {
+		return
+	}
+	var payload ReviewDecisionPayload
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	adminID, _ := c.Get("user_id")
+	review, err := services.ClaimFraudReview(database.DB, id, status, adminID.(uint), payload.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"message": "Review not found"})
+		case errors.Is(err, services.ErrReviewNotPending):
+			c.JSON(http.StatusConflict, gin.H{"message": "Review was already decided"})
+		case errors.Is(err, services.ErrOwnReview):
+			c.JSON(http.StatusForbidden, gin.H{"message": "You cannot decide a review of your own transfer"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update review"})
+		}
+		return
+	}
+	if status == models.ReviewApproved {
+		sender, err := GetDataUser(review.SenderID)
+		if err == nil {
+			var receiver models.User
+			receiver, err = GetDataUser(review.ReceiverID)
+			if err == nil {
+				transaction, terr := executeTransfer(sender, receiver, review.Amount, nil, nil)
+				if terr != nil {
+					services.ReopenFraudReview(database.DB, review.ID)
+					respondTransferError(c, terr)
+					return
+				}
+				review.TransactionID = &transaction.ID
+				database.DB.Model(&review).Update("transaction_id", transaction.ID)
+				recordAudit(c, services.AuditTransfer, "transaction", fmt.Sprint(transaction.ID), map[string]interface{}{
+					"sender_id":   sender.ID,
+					"receiver_id": receiver.ID,
+					"amount":      transaction.Amount,
+					"review_id":   review.ID,
+				})
+			}
+		}
+		if err != nil {
+			services.ReopenFraudReview(database.DB, review.ID)
+			respondUserLookupError(c, err, "Sender or receiver no longer exists")
+			return
+		}
+	}
+	c.JSON(http.StatusOK, review)
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"gotestbackend/database"
+	"gotestbackend/middlewares"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes returned alongside the message when a transfer is refused
+const (
+	codeInvalidAmount      = "INVALID_AMOUNT"
+	codeSameAccount        = "SAME_ACCOUNT"
+	codeInsufficientCredit = "INSUFFICIENT_CREDIT"
+	codeSenderFrozen       = "SENDER_FROZEN"
+	codeSenderDormant      = "SENDER_DORMANT"
+	codeSenderClosed       = "SENDER_CLOSED"
+	codeReceiverFrozen     = "RECEIVER_FROZEN"
+	codeReceiverClosed     = "RECEIVER_CLOSED"
+	codeReceiverSystem     = "RECEIVER_NOT_ALLOWED"
+	codeContactUnverified  = "CONTACT_UNVERIFIED"
+	codeTransferFailed     = "TRANSFER_FAILED"
+	codeQuoteChanged       = "QUOTE_CHANGED"
+	codeQuoteUsed          = "QUOTE_USED"
+)
+
+// transferError is a rule violation that stops a transfer
+type transferError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *transferError) Error() string {
+	return e.Message
+}
+
+// respondTransferError writes a refused transfer as {"code", "message"}
+func respondTransferError(c *gin.Context, terr *transferError) {
+	c.JSON(terr.Status, gin.H{"code": terr.Code, "message": terr.Message})
+}
+
+// checkAccountStatus refuses transfers that touch frozen or closed accounts.
+// Dormant accounts can still receive but must be reactivated before sending.
+func checkAccountStatus(sender, receiver models.User) *transferError {
+	switch sender.Status {
+	case models.AccountFrozen:
+		return &transferError{Status: http.StatusForbidden, Code: codeSenderFrozen, Message: "Sender account is frozen"}
+	case models.AccountDormant:
+		return &transferError{Status: http.StatusForbidden, Code: codeSenderDormant, Message: "Sender account is dormant"}
+	case models.AccountClosed:
+		return &transferError{Status: http.StatusForbidden, Code: codeSenderClosed, Message: "Sender account is closed"}
+	}
+	switch receiver.Status {
+	case models.AccountFrozen:
+		return &transferError{Status: http.StatusUnprocessableEntity, Code: codeReceiverFrozen, Message: "Receiver account is frozen"}
+	case models.AccountClosed:
+		return &transferError{Status: http.StatusUnprocessableEntity, Code: codeReceiverClosed, Message: "Receiver account is closed"}
+	}
+	return nil
+}
+
+// checkTransferRules runs the status, balance and limit rules shared by Transfer and TransferInquiry.
+// fee is charged on top of amount and must be covered too.
+func checkTransferRules(sender, receiver models.User, amount, fee float64) *transferError {
+	// NaN fails every comparison, so it would slip past the check below
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return &transferError{Status: http.StatusBadRequest, Code: codeInvalidAmount, Message: "Amount must be a number"}
+	}
+	if amount <= 0 {
+		return &transferError{Status: http.StatusBadRequest, Code: codeInvalidAmount, Message: "Amount must be greater than zero"}
+	}
+	if sender.ID == receiver.ID {
+		return &transferError{Status: http.StatusBadRequest, Code: codeSameAccount, Message: "Cannot transfer to your own account"}
+	}
+	if receiver.Role == models.RoleSystem {
+		return &transferError{Status: http.StatusUnprocessableEntity, Code: codeReceiverSystem, Message: "Receiver account cannot accept transfers"}
+	}
+	if terr := checkAccountStatus(sender, receiver); terr != nil {
+		return terr
+	}
+	if services.RequireVerifiedContact && !services.HasVerifiedContact(sender) {
+		return &transferError{Status: http.StatusForbidden, Code: codeContactUnverified, Message: "Verify your email or phone before sending transfers"}
+	}
+	// Validate if sender has enough credit, counting any overdraft
+	if services.AvailableBalance(sender) < amount+fee {
+		return &transferError{Status: http.StatusBadRequest, Code: codeInsufficientCredit, Message: "Insufficient credit"}
+	}
+	return nil
+}
+
+// maskName keeps the first letter of each word, e.g. "John Doe" becomes "J*** D**"
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		r := []rune(w)
+		words[i] = string(r[0]) + strings.Repeat("*", len(r)-1)
+	}
+	return strings.Join(words, " ")
+}
+
+// TransferInquiryResponse is returned by the transfer pre-check
+type TransferInquiryResponse struct {
+	ReceiverAccount   string    `json:"receiver_account"`
+	ReceiverFirstName string    `json:"receiver_first_name"`
+	ReceiverLastName  string    `json:"receiver_last_name"`
+	Amount            float64   `json:"amount"`
+	Fee               float64   `json:"fee"`
+	Total             float64   `json:"total"`
+	QuoteToken        string    `json:"quote_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// TransferInquiry resolves the receiver and checks a transfer without moving money
+//
+//	@Summary		transferInquiry
+//	@Description	Resolves the receiver, runs the transfer rules and returns masked names with a quote token for Transfer. API keys need read:transfers
+//	@Tags			accounting
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			receiver_account	query		string	false	"Receiver account number"
+//	@Param			alias_type			query		string	false	"phone, email or username, instead of receiver_account"
+//	@Param			alias_value			query		string	false	"Alias to pay"
+//	@Param			amount				query		number	true	"Amount to transfer"
+//	@Success		200					{object}	TransferInquiryResponse
+//	@Failure		400					{object}	map[string]string	"message"
+//	@Failure		401					{object}	map[string]string	"message"
+//	@Failure		403					{object}	map[string]string	"code and message"
+//	@Failure		404					{object}	map[string]string	"message"
+//	@Failure		422					{object}	map[string]string	"code and message"
+//	@Failure		500					{object}	map[string]string	"message"
+//	@Router			/accounting/transfer/inquiry [get]
+func TransferInquiry(c *gin.Context) {
+	if !requireScope(c, models.ScopeReadTransfers) {
+		return
+	}
+	idparam, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "User not logged in"})
+		return
+	}
+	userID, ok := idparam.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Invalid user ID"})
+		return
+	}
+	receiverAccount, ok := resolveReceiverAccount(c, c.Query("receiver_account"), c.Query("alias_type"), c.Query("alias_value"))
+	if !ok {
+		return
+	}
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid amount"})
+		return
+	}
+	sender, err := GetDataUser(userID)
+	if err != nil {
+		respondUserLookupError(c, err, "Sender not found")
+		return
+	}
+	receiver, err := GetDataUserByAccount(receiverAccount)
+	if err != nil {
+		respondUserLookupError(c, err, "Receiver not found")
+		return
+	}
+	fee, err := services.TransferFee(database.DB, sender, amount, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to calculate fee"})
+		return
+	}
+	if terr := checkTransferRules(sender, receiver, amount, fee); terr != nil {
+		respondTransferError(c, terr)
+		return
+	}
+	token, expiresAt, err := middlewares.GenerateQuoteToken(sender.ID, receiverAccount, amount, fee)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate quote"})
+		return
+	}
+	c.JSON(http.StatusOK, TransferInquiryResponse{
+		ReceiverAccount:   receiver.AccountNumber,
+		ReceiverFirstName: maskName(receiver.FirstName),
+		ReceiverLastName:  maskName(receiver.LastName),
+		Amount:            amount,
+		Fee:               fee,
+		Total:             amount + fee,
+		QuoteToken:        token,
+		ExpiresAt:         expiresAt,
+	})
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetInterestProductPayload is used to bind an interest product assignment
+type SetInterestProductPayload struct {
+	// ProductID of 0 removes the account from interest
+	ProductID uint `json:"product_id"`
+}
+
+// RunInterestPayload is used to bind an interest run over a date range
+type RunInterestPayload struct {
+	From string `json:"from" binding:"required" example:"2024-06-01"`
+	To   string `json:"to" binding:"required" example:"2024-06-30"`
+}
+
+// CreateInterestProduct adds a savings interest product
+//
+//	@Summary		createInterestProduct
+//	@Description	Adds an interest product with an annual rate (percent) and day-count convention
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			product	body		models.InterestProduct	true	"Interest product"
+//	@Success		201		{object}	models.InterestProduct
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/interest-products [post]
+func CreateInterestProduct(c *gin.Context) {
+	var product models.InterestProduct
+	if err := c.ShouldBindJSON(&product); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	product.ID = 0
+	product.Active = true
+	if err := services.ValidateInterestProduct(product); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := database.DB.Create(&product).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save interest product"})
+		return
+	}
+	c.JSON(http.StatusCreated, product)
+}
+
+// GetInterestProducts lists interest products
+//
+//	@Summary		getInterestProducts
+//	@Description	Lists every interest product
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	[]models.InterestProduct
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/interest-products [get]
+func GetInterestProducts(c *gin.Context) {
+	var products []models.InterestProduct
+	if err := database.DB.Order("id").Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch interest products"})
+		return
+	}
+	c.JSON(http.StatusOK, products)
+}
+
+// SetInterestProduct puts a user's account on an interest product
+//
+//	@Summary		setInterestProduct
+//	@Description	Puts an account on an interest product, or takes it off with product_id 0
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string						true	"User ID"
+//	@Param			payload	body		SetInterestProductPayload	true	"Product"
+//	@Success		200		{object}	models.User
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/interest-product [put]
+func SetInterestProduct(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var payload SetInterestProductPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	user, err := GetDataUser(id)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	var productID *uint
+	if payload.ProductID != 0 {
+		var product models.InterestProduct
+		if err := database.DB.First(&product, payload.ProductID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Interest product not found"})
+			return
+		}
+		productID = &product.ID
+	}
+	if err := database.DB.Model(&user).Update("interest_product_id", productID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update user"})
+		return
+	}
+	user.InterestProductID = productID
+	c.JSON(http.StatusOK, user)
+}
+
+// RunInterest accrues and posts interest over a date range
+//
+//	@Summary		runInterest
+//	@Description	Accrues daily interest from..to (inclusive) and posts each month that ends in the range. Safe to rerun; days and months already done are skipped
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RunInterestPayload	true	"Date range"
+//	@Success		200		{object}	services.InterestRunResult
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/interest/run [post]
+func RunInterest(c *gin.Context) {
+	var payload RunInterestPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	layout := "2006-01-02"
+	from, err := time.ParseInLocation(layout, payload.From, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid from date"})
+		return
+	}
+	to, err := time.ParseInLocation(layout, payload.To, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid to date"})
+		return
+	}
+	result, err := services.RunInterest(database.DB, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDateRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "from must not be after to"})
+			return
+		}
+		if errors.Is(err, services.ErrDayNotEnded) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "to must be before today"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Interest run failed", "result": result})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetInterestAccruals lists a user's daily interest accruals
+//
+//	@Summary		getInterestAccruals
+//	@Description	Lists the daily interest accruals of a user, newest first
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string	true	"User ID"
+//	@Success		200	{object}	[]models.InterestAccrual
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/interest-accruals [get]
+func GetInterestAccruals(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var accruals []models.InterestAccrual
+	if err := database.DB.Where("user_id = ?", id).Order("accrual_date DESC").Find(&accruals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch accruals"})
+		return
+	}
+	c.JSON(http.StatusOK, accruals)
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// dummyPasswordHash is compared against when the username does not exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+
+// checkLoginThrottle answers 429 while the username or client IP is held back
+// after failed logins. It reports whether the login may go ahead.
+func checkLoginThrottle(c *gin.Context, username string) bool {
+	now := time.Now()
+	until, blocked, err := services.LoginBlockedUntil(database.DB, username, c.ClientIP(), now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Login is unavailable"})
+		return false
+	}
+	if blocked {
+		c.Header("Retry-After", fmt.Sprint(int(math.Ceil(until.Sub(now).Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"message": "Too many failed attempts, try again later"})
+		return false
+	}
+	return true
+}
+
+// recordFailedAttempt counts a wrong password or code against the login
+// throttle and audits it. It answers 500 and reports false if the failure
+// could not be counted.
+func recordFailedAttempt(c *gin.Context, username, step string) bool {
+	if err := services.RecordLoginFailure(database.DB, username, c.ClientIP(), time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Login is unavailable"})
+		return false
+	}
+	recordAudit(c, services.AuditLoginFailure, "username", username, map[string]interface{}{"step": step})
+	return true
+}
+
+// failLogin counts a failed login and answers with a message that does not say which part was wrong
+func failLogin(c *gin.Context, username string) {
+	if recordFailedAttempt(c, username, "password") {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid username or password"})
+	}
+}
+
+// GetLoginLockouts lists usernames and IPs with recent failed logins
+//
+//	@Summary		getLoginLockouts
+//	@Description	Lists login failure counters, most recent first. With active=true only those locked right now
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			active	query		bool	false	"Only currently locked"
+//	@Success		200		{object}	[]models.LoginThrottle
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/login-lockouts [get]
+func GetLoginLockouts(c *gin.Context) {
+	query := database.DB.Order("last_failure_at DESC")
+	if c.Query("active") == "true" {
+		query = query.Where("locked_until > ?", time.Now())
+	}
+	var throttles []models.LoginThrottle
+	if err := query.Find(&throttles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch lockouts"})
+		return
+	}
+	c.JSON(http.StatusOK, throttles)
+}
+
+// ClearLoginLockout resets the failure counter of a username or IP
+//
+//	@Summary		clearLoginLockout
+//	@Description	Clears a login failure counter and any lockout it holds
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string	true	"Lockout ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/login-lockouts/{id} [delete]
+func ClearLoginLockout(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid lockout ID"})
+		return
+	}
+	var throttle models.LoginThrottle
+	if err := database.DB.First(&throttle, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Lockout not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load lockout"})
+		return
+	}
+	if err := database.DB.Delete(&throttle).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to clear lockout"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared"})
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// postJSON sends body to handler mounted at path and returns the response
+func postJSON(t *testing.T, path string, handler gin.HandlerFunc, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	r := gin.New()
+	r.POST(path, handler)
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestBootstrapAdminMustResetPassword(t *testing.T) {
+	db := testutil.NewDB(t)
+	if created, err := services.BootstrapAdmin(db, "root", "Bootstrap-Pass-4821"); err != nil || !created {
+		t.Fatalf("BootstrapAdmin: created %v, err %v", created, err)
+	}
+	if created, err := services.BootstrapAdmin(db, "root2", "Bootstrap-Pass-4821"); err != nil || created {
+		t.Fatalf("second BootstrapAdmin: created %v, err %v; want no second admin", created, err)
+	}
+
+	w := postJSON(t, "/user/login", Login, map[string]string{"username": "root", "password": "Bootstrap-Pass-4821"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("login status = %d, want 403; body %s", w.Code, w.Body)
+	}
+	var refused struct {
+		Code       string `json:"code"`
+		ResetToken string `json:"reset_token"`
+		Token      string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &refused); err != nil {
+		t.Fatal(err)
+	}
+	if refused.Code != codePasswordResetRequired || refused.ResetToken == "" || refused.Token != "" {
+		t.Fatalf("login body = %s, want a reset token and no session", w.Body)
+	}
+
+	w = postJSON(t, "/user/password/reset", ResetPassword, map[string]string{"token": refused.ResetToken, "new_password": "Fresh-Secret-7390x"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("reset status = %d; body %s", w.Code, w.Body)
+	}
+	w = postJSON(t, "/user/login", Login, map[string]string{"username": "root", "password": "Fresh-Secret-7390x"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login after reset = %d, want 200; body %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/middlewares"
+	"gotestbackend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// MFAChallengeResponse is what Login returns to users with two-factor authentication
+type MFAChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// MFAEnrollmentResponse carries a new TOTP secret for the user's authenticator app
+type MFAEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	// PNG is the provisioning URI as a base64-encoded QR image
+	PNG []byte `json:"png" swaggertype:"string" format:"base64"`
+}
+
+// MFACodePayload is used to bind a TOTP or recovery code
+type MFACodePayload struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// LoginMFAPayload is used to bind the second login step
+type LoginMFAPayload struct {
+	MFAToken    string `json:"mfa_token" binding:"required"`
+	Code        string `json:"code" binding:"required"`
+	DeviceLabel string `json:"device_label"`
+}
+
+// EnrollMFA starts TOTP enrolment for the logged-in user
+//
+//	@Summary		enrollMFA
+//	@Description	Issues a TOTP secret with an otpauth:// provisioning URI and its QR. Login is not protected until the enrolment is confirmed
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	MFAEnrollmentResponse
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		409	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/mfa [post]
+func EnrollMFA(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	user, err := GetDataUser(userID.(uint))
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	secret, uri, err := services.BeginMFAEnrollment(database.DB, user)
+	if err != nil {
+		if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to start enrolment"})
+		return
+	}
+	png, err := qrcode.Encode(uri, qrcode.Medium, qrImageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to render QR"})
+		return
+	}
+	c.JSON(http.StatusOK, MFAEnrollmentResponse{Secret: secret, ProvisioningURI: uri, PNG: png})
+}
+
+// ConfirmMFA turns on two-factor authentication for the logged-in user
+//
+//	@Summary		confirmMFA
+//	@Description	Checks a code from the authenticator and enables two-factor authentication. Returns one-time recovery codes, which are not shown again. Admins then log in again with a code to open admin routes
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MFACodePayload	true	"Code from the authenticator"
+//	@Success		200		{object}	map[string][]string	"recovery_codes"
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/user/me/mfa/confirm [post]
+func ConfirmMFA(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var payload MFACodePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	codes, err := services.ConfirmMFAEnrollment(database.DB, userID.(uint), payload.Code, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMFANotEnrolled):
+			c.JSON(http.StatusNotFound, gin.H{"message": "Start enrolment first"})
+		case errors.Is(err, services.ErrMFAAlreadyEnabled):
+			c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		case errors.Is(err, services.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to confirm enrolment"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// LoginMFA exchanges an MFA challenge token and a code for the session JWT
+//
+//	@Summary		loginMFA
+//	@Description	Second login step for users with two-factor authentication. Accepts a TOTP code or an unused recovery code
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		LoginMFAPayload		true	"Challenge token and code"
+//	@Success		200		{object}	map[string]string	"token"
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		429		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/user/login/mfa [post]
+func LoginMFA(c *gin.Context) {
+	var payload LoginMFAPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	claims, err := middlewares.ParseMFAChallengeToken(payload.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid or expired MFA token"})
+		return
+	}
+	user, err := GetDataUser(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid or expired MFA token"})
+		return
+	}
+	// Wrong codes count against the same throttle as wrong passwords
+	if !checkLoginThrottle(c, user.Username) {
+		return
+	}
+	if err := services.VerifyMFACode(database.DB, user.ID, payload.Code, time.Now()); err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, services.ErrMFANotEnrolled) {
+			if !recordFailedAttempt(c, user.Username, "mfa") {
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authentication code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to verify code"})
+		return
+	}
+	issueSession(c, user, payload.DeviceLabel, true)
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/middlewares"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondOIDCError maps OIDC failures onto HTTP statuses
+func respondOIDCError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownOIDCProvider):
+		c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+	case errors.Is(err, services.ErrOIDCState), errors.Is(err, services.ErrOIDCExchange):
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+	case errors.Is(err, services.ErrOIDCSignupDisabled):
+		c.JSON(http.StatusForbidden, gin.H{"message": err.Error()})
+	case errors.Is(err, services.ErrIdentityLinked):
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "OIDC login failed"})
+	}
+}
+
+// StartOIDCLogin begins an OpenID Connect login
+//
+//	@Summary		startOIDCLogin
+//	@Description	Returns the provider URL to send the browser to (authorization code flow with PKCE)
+//	@Tags			Auth
+//	@Produce		json
+//	@Param			provider		path		string				true	"Provider name"
+//	@Param			device_label	query		string				false	"Name for the session"
+//	@Success		200				{object}	map[string]string	"authorization_url"
+//	@Failure		404				{object}	map[string]string	"message"
+//	@Failure		500				{object}	map[string]string	"message"
+//	@Router			/auth/oidc/{provider}/login [get]
+func StartOIDCLogin(c *gin.Context) {
+	authURL, err := services.StartOIDCLogin(database.DB, c.Param("provider"), nil, c.Query("device_label"))
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// OIDCCallback finishes an OpenID Connect login or identity link
+//
+//	@Summary		oidcCallback
+//	@Description	The provider redirects here. Logs in the linked user (creating one if the provider allows signup) and returns our JWT, or an MFA challenge for users with two-factor authentication. For a link request, links the identity instead
+//	@Tags			Auth
+//	@Produce		json
+//	@Param			provider	path		string	true	"Provider name"
+//	@Param			code		query		string	true	"Authorization code"
+//	@Param			state		query		string	true	"State"
+//	@Success		200			{object}	map[string]string	"token"
+//	@Failure		400			{object}	map[string]string	"message"
+//	@Failure		401			{object}	map[string]string	"message"
+//	@Failure		403			{object}	map[string]string	"message"
+//	@Failure		404			{object}	map[string]string	"message"
+//	@Failure		409			{object}	map[string]string	"message"
+//	@Failure		500			{object}	map[string]string	"message"
+//	@Router			/auth/oidc/{provider}/callback [get]
+func OIDCCallback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Provider refused the login: " + providerError})
+		return
+	}
+	if c.Query("code") == "" || c.Query("state") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "code and state are required"})
+		return
+	}
+	result, err := services.FinishOIDCLogin(database.DB, c.Param("provider"), c.Query("state"), c.Query("code"))
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+	if result.Linked {
+		c.JSON(http.StatusOK, gin.H{"message": "Identity linked", "identity": result.Identity})
+		return
+	}
+	mfaEnabled, err := services.MFAEnabled(database.DB, result.User.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not check two-factor authentication"})
+		return
+	}
+	if mfaEnabled {
+		challenge, expiresAt, err := middlewares.GenerateMFAChallengeToken(result.User.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, MFAChallengeResponse{MFARequired: true, MFAToken: challenge, ExpiresAt: expiresAt})
+		return
+	}
+	issueSession(c, result.User, result.DeviceLabel, false)
+}
+
+// LinkIdentity starts linking an OpenID Connect identity to the logged-in user
+//
+//	@Summary		linkIdentity
+//	@Description	Returns the provider URL to send the browser to; the callback links the identity to the logged-in user
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			provider	path		string				true	"Provider name"
+//	@Success		200			{object}	map[string]string	"authorization_url"
+//	@Failure		401			{object}	map[string]string	"message"
+//	@Failure		404			{object}	map[string]string	"message"
+//	@Failure		500			{object}	map[string]string	"message"
+//	@Router			/user/me/identities/{provider} [post]
+func LinkIdentity(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id := userID.(uint)
+	authURL, err := services.StartOIDCLogin(database.DB, c.Param("provider"), &id, "")
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// GetIdentities lists the logged-in user's linked identities
+//
+//	@Summary		getIdentities
+//	@Description	Lists the OpenID Connect identities linked to the logged-in user
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	[]models.ExternalIdentity
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/identities [get]
+func GetIdentities(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var identities []models.ExternalIdentity
+	if err := database.DB.Where("user_id = ?", userID).Order("id").Find(&identities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch identities"})
+		return
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+// UnlinkIdentity removes a linked identity from the logged-in user
+//
+//	@Summary		unlinkIdentity
+//	@Description	Unlinks an OpenID Connect identity; it can no longer be used to log in
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string	true	"Identity ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/identities/{id} [delete]
+func UnlinkIdentity(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid identity ID"})
+		return
+	}
+	if err := services.UnlinkIdentity(database.DB, userID.(uint), uint(id)); err != nil {
+		if errors.Is(err, services.ErrIdentityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Identity not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to unlink identity"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked"})
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetOverdraftLimitPayload is used to bind an overdraft limit change
+type SetOverdraftLimitPayload struct {
+	Limit  *float64 `json:"limit" binding:"required"`
+	Reason string   `json:"reason" binding:"required"`
+}
+
+// SetOverdraftLimit sets how far below zero a user's credit may go
+//
+//	@Summary		setOverdraftLimit
+//	@Description	Sets a user's overdraft limit. Every change is kept with the admin and reason
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string						true	"User ID"
+//	@Param			payload	body		SetOverdraftLimitPayload	true	"New limit"
+//	@Success		200		{object}	models.User
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/overdraft-limit [put]
+func SetOverdraftLimit(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var payload SetOverdraftLimitPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	adminID, _ := c.Get("user_id")
+	if _, err := services.SetOverdraftLimit(database.DB, id, *payload.Limit, payload.Reason, adminID.(uint)); err != nil {
+		if errors.Is(err, services.ErrInvalidOverdraftLimit) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	user, err := GetDataUser(id)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// GetOverdraftLimitHistory lists every change to a user's overdraft limit
+//
+//	@Summary		getOverdraftLimitHistory
+//	@Description	Lists overdraft limit changes of a user, newest first
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string	true	"User ID"
+//	@Success		200	{object}	[]models.OverdraftLimitChange
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/overdraft-limit/history [get]
+func GetOverdraftLimitHistory(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	var changes []models.OverdraftLimitChange
+	if err := database.DB.Where("user_id = ?", id).Order("created_at DESC, id DESC").Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch overdraft history"})
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
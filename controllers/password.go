@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// codePasswordResetRequired tells a client the password must be reset before logging in
+const codePasswordResetRequired = "PASSWORD_RESET_REQUIRED"
+
+// ForgotPasswordPayload is used to bind a password reset request
+type ForgotPasswordPayload struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// ResetPasswordPayload is used to bind a password reset
+type ResetPasswordPayload struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// respondPasswordError writes a policy violation as 400 and anything else as 500
+func respondPasswordError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrWeakPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to set password"})
+}
+
+// mergeNames is user with the username and names an update is about to set,
+// so the password is checked against the names it will live alongside
+func mergeNames(user, update models.User) models.User {
+	if update.Username != "" {
+		user.Username = update.Username
+	}
+	if update.FirstName != "" {
+		user.FirstName = update.FirstName
+	}
+	if update.LastName != "" {
+		user.LastName = update.LastName
+	}
+	return user
+}
+
+// ForgotPassword sends a password reset token to the user
+//
+//	@Summary		forgotPassword
+//	@Description	Sends a single-use reset token through the notifier. The answer is the same whether or not the username exists
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"Username"
+//	@Success		202		{object}	map[string]string		"message"
+//	@Failure		400		{object}	map[string]string		"message"
+//	@Failure		500		{object}	map[string]string		"message"
+//	@Router			/user/password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	var payload ForgotPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := services.RequestPasswordReset(database.DB, payload.Username, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to send reset token"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a reset token has been sent"})
+}
+
+// ResetPassword sets a new password with a reset token
+//
+//	@Summary		resetPassword
+//	@Description	Uses up a reset token and sets a new password. Every existing session is signed out
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Token and new password"
+//	@Success		200		{object}	map[string]string		"message"
+//	@Failure		400		{object}	map[string]string		"message"
+//	@Failure		500		{object}	map[string]string		"message"
+//	@Router			/user/password/reset [post]
+func ResetPassword(c *gin.Context) {
+	var payload ResetPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := services.ResetPassword(database.DB, payload.Token, payload.NewPassword, time.Now()); err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		respondPasswordError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
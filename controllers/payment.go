@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DepositPayload is used to bind a top-up
+type DepositPayload struct {
+	Amount float64 `json:"amount" binding:"required"`
+	// Rail defaults to services.DefaultPaymentRail, if one is configured
+	Rail string `json:"rail"`
+	// Source is the bank account or card the money comes from
+	Source string `json:"source" binding:"required"`
+}
+
+// WithdrawalPayload is used to bind a withdrawal
+type WithdrawalPayload struct {
+	Amount float64 `json:"amount" binding:"required"`
+	// Rail defaults to services.DefaultPaymentRail, if one is configured
+	Rail string `json:"rail"`
+	// Destination is the bank account or card the money goes to
+	Destination string `json:"destination" binding:"required"`
+	// PIN is the transaction PIN, required from services.PINThreshold
+	PIN string `json:"pin"`
+}
+
+// respondPaymentError maps payment errors onto responses
+func respondPaymentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPaymentAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"code": codeInvalidAmount, "message": "Amount must be greater than zero with at most two decimals"})
+	case errors.Is(err, services.ErrUnknownPaymentRail):
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown payment rail"})
+	case errors.Is(err, services.ErrPaymentAccountUnavailable):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Account cannot make this payment in its current status"})
+	case errors.Is(err, services.ErrPaymentInsufficientCredit):
+		c.JSON(http.StatusBadRequest, gin.H{"code": codeInsufficientCredit, "message": "Insufficient credit"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Payment failed"})
+	}
+}
+
+// respondPayment answers 202 while the rail is still working and 200 once it is settled
+func respondPayment(c *gin.Context, payment models.Payment) {
+	recordAudit(c, services.AuditPayment, "payment", fmt.Sprint(payment.ID), map[string]interface{}{
+		"kind":   payment.Kind,
+		"rail":   payment.Rail,
+		"amount": payment.Amount,
+		"status": payment.Status,
+	})
+	if payment.Status == models.PaymentPending {
+		c.JSON(http.StatusAccepted, payment)
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// Deposit tops up the logged-in user's account from outside
+//
+//	@Summary		deposit
+//	@Description	Asks the payment rail to pull money in. The payment is pending until the rail calls back; the account is credited from the settlement account on success
+//	@Tags			accounting
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DepositPayload	true	"Deposit"
+//	@Success		200		{object}	models.Payment
+//	@Success		202		{object}	models.Payment
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/accounting/deposits [post]
+func Deposit(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var payload DepositPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	user, err := GetDataUser(userID.(uint))
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	payment, err := services.StartDeposit(database.DB, user, payload.Rail, payload.Source, payload.Amount)
+	if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+	respondPayment(c, payment)
+}
+
+// Withdraw pays money out of the logged-in user's account
+//
+//	@Summary		withdraw
+//	@Description	Holds the amount on the account and asks the payment rail to pay it out. Held credit cannot be spent; it is posted to the settlement account when the rail reports success and released if it fails. The transaction PIN is required for large withdrawals
+//	@Tags			accounting
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		WithdrawalPayload	true	"Withdrawal"
+//	@Success		200		{object}	models.Payment
+//	@Success		202		{object}	models.Payment
+//	@Failure		400		{object}	map[string]string	"code and message"
+//	@Failure		403		{object}	map[string]string	"code and message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		423		{object}	map[string]string	"code and message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/accounting/withdrawals [post]
+func Withdraw(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var payload WithdrawalPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	user, err := GetDataUser(userID.(uint))
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	if payload.Amount >= services.PINThreshold {
+		if terr := checkTransferPIN(user.ID, payload.PIN); terr != nil {
+			respondTransferError(c, terr)
+			return
+		}
+	}
+	payment, err := services.StartWithdrawal(database.DB, user, payload.Rail, payload.Destination, payload.Amount)
+	if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+	respondPayment(c, payment)
+}
+
+// GetPayments lists the logged-in user's deposits and withdrawals
+//
+//	@Summary		getPayments
+//	@Description	Lists deposits and withdrawals, newest first
+//	@Tags			accounting
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			status	query		string	false	"pending, succeeded or failed"
+//	@Success		200		{object}	[]models.Payment
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/accounting/payments [get]
+func GetPayments(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	query := database.DB.Where("user_id = ?", userID).Order("id DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var payments []models.Payment
+	if err := query.Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch payments"})
+		return
+	}
+	c.JSON(http.StatusOK, payments)
+}
+
+// GetPayment shows one of the logged-in user's deposits or withdrawals
+//
+//	@Summary		getPayment
+//	@Description	Shows a deposit or withdrawal and its state
+//	@Tags			accounting
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Payment ID"
+//	@Success		200	{object}	models.Payment
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Router			/accounting/payments/{id} [get]
+func GetPayment(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid payment ID"})
+		return
+	}
+	var payment models.Payment
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&payment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Payment not found"})
+		return
+	}
+	c.JSON(http.StatusOK, payment)
+}
+
+// PaymentCallback receives a payment rail's report on a deposit or withdrawal
+//
+//	@Summary		paymentCallback
+//	@Description	Called by payment rails, not users. The rail signs the body; unsigned or wrongly signed callbacks are refused. Repeating a callback is harmless
+//	@Tags			accounting
+//	@Accept			json
+//	@Produce		json
+//	@Param			rail	path		string	true	"Rail name"
+//	@Success		200		{object}	models.Payment
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/payments/callbacks/{rail} [post]
+func PaymentCallback(c *gin.Context) {
+	railName := c.Param("rail")
+	rail, ok := services.PaymentRails[railName]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Unknown payment rail"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<16))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Could not read callback"})
+		return
+	}
+	callback, err := rail.ParseCallback(c.Request.Header, body)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCallbackSignature) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid signature"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	payment, err := services.CompletePayment(database.DB, railName, callback)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, payment)
+	case errors.Is(err, services.ErrInvalidCallback):
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	case errors.Is(err, services.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Payment not found"})
+	case errors.Is(err, services.ErrPaymentRailMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"message": "Payment was not made on this rail"})
+	case errors.Is(err, services.ErrPaymentAlreadySettled):
+		c.JSON(http.StatusConflict, gin.H{"message": "Payment already settled with a different outcome"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to settle payment"})
+	}
+}
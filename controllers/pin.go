@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Error codes returned when a transfer needs the transaction PIN
+const (
+	codePINRequired = "PIN_REQUIRED"
+	codePINNotSet   = "PIN_NOT_SET"
+	codePINInvalid  = "PIN_INVALID"
+	codePINLocked   = "PIN_LOCKED"
+)
+
+// SetPINPayload is used to bind a PIN set or reset request
+type SetPINPayload struct {
+	Password string `json:"password" binding:"required"`
+	PIN      string `json:"pin" binding:"required"`
+}
+
+// checkTransferPIN verifies the PIN a transfer was sent with
+func checkTransferPIN(userID uint, pin string) *transferError {
+	if pin == "" {
+		return &transferError{Status: http.StatusForbidden, Code: codePINRequired, Message: "Transaction PIN required for this transfer"}
+	}
+	err := services.VerifyTransactionPIN(database.DB, userID, pin, time.Now())
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, services.ErrPINNotSet):
+		return &transferError{Status: http.StatusForbidden, Code: codePINNotSet, Message: "Set a transaction PIN before sending this transfer"}
+	case errors.Is(err, services.ErrWrongPIN):
+		return &transferError{Status: http.StatusForbidden, Code: codePINInvalid, Message: "Wrong transaction PIN"}
+	case errors.Is(err, services.ErrPINLocked):
+		return &transferError{Status: http.StatusLocked, Code: codePINLocked, Message: "Transaction PIN locked after too many wrong attempts"}
+	}
+	return &transferError{Status: http.StatusInternalServerError, Code: codeTransferFailed, Message: "Failed to verify PIN"}
+}
+
+// GetPINStatus shows whether the logged-in user has a transaction PIN
+//
+//	@Summary		getPINStatus
+//	@Description	Shows whether a transaction PIN is set and whether it is locked
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	models.TransactionPIN
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/pin [get]
+func GetPINStatus(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var record models.TransactionPIN
+	if err := database.DB.First(&record, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Transaction PIN not set"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load PIN"})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// SetPIN sets or resets the logged-in user's transaction PIN
+//
+//	@Summary		setPIN
+//	@Description	Sets the 6-digit transaction PIN, or resets a forgotten or locked one. The login password must be re-entered
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		SetPINPayload		true	"Password and new PIN"
+//	@Success		200		{object}	map[string]string	"message"
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		429		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/user/me/pin [put]
+func SetPIN(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	var payload SetPINPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	user, err := GetDataUser(userID.(uint))
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	// Wrong passwords count against the same throttle as failed logins
+	if !checkLoginThrottle(c, user.Username) {
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.Password)); err != nil {
+		if !recordFailedAttempt(c, user.Username, "set_pin") {
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid credentials"})
+		return
+	}
+	if err := services.SetTransactionPIN(database.DB, user.ID, payload.PIN); err != nil {
+		if errors.Is(err, services.ErrInvalidPINFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to set PIN"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction PIN set"})
+}
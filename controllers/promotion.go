@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GrantPromotionPayload is used to bind an admin granting a promotion
+type GrantPromotionPayload struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
+
+// parsePromotionID reads the :id path parameter of a promotion
+func parsePromotionID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid promotion ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// CreatePromotion adds a promotion campaign
+//
+//	@Summary		createPromotion
+//	@Description	Adds a campaign paying amount once to each eligible user from the promotions account, up to budget_cap. Sign-up campaigns are paid when an account opens; manual ones are granted by an admin
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			promotion	body		models.Promotion	true	"Promotion"
+//	@Success		201			{object}	models.Promotion
+//	@Failure		400			{object}	map[string]string	"message"
+//	@Failure		403			{object}	map[string]string	"message"
+//	@Failure		500			{object}	map[string]string	"message"
+//	@Router			/admin/promotions [post]
+func CreatePromotion(c *gin.Context) {
+	var promotion models.Promotion
+	if err := c.ShouldBindJSON(&promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	promotion.ID = 0
+	promotion.Spent = 0
+	promotion.GrantCount = 0
+	promotion.Active = true
+	if err := services.ValidatePromotion(promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err := database.DB.Create(&promotion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save promotion"})
+		return
+	}
+	c.JSON(http.StatusCreated, promotion)
+}
+
+// GetPromotions lists promotion campaigns
+//
+//	@Summary		getPromotions
+//	@Description	Lists every campaign with what it has spent so far
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	[]models.Promotion
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/promotions [get]
+func GetPromotions(c *gin.Context) {
+	var promotions []models.Promotion
+	if err := database.DB.Order("id DESC").Find(&promotions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch promotions"})
+		return
+	}
+	c.JSON(http.StatusOK, promotions)
+}
+
+// DeactivatePromotion stops a campaign from granting
+//
+//	@Summary		deactivatePromotion
+//	@Description	Deactivates a campaign; it and its grants are kept for reporting
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int					true	"Promotion ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/promotions/{id} [delete]
+func DeactivatePromotion(c *gin.Context) {
+	id, ok := parsePromotionID(c)
+	if !ok {
+		return
+	}
+	result := database.DB.Model(&models.Promotion{}).Where("id = ?", id).Update("active", false)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to deactivate promotion"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if database.DB.Model(&models.Promotion{}).Where("id = ?", id).Count(&count); count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Promotion not found"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion deactivated"})
+}
+
+// GrantPromotion pays a campaign to one user
+//
+//	@Summary		grantPromotion
+//	@Description	Pays the campaign's amount to the user as a ledger transaction from the promotions account, if they are eligible and the budget allows
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Promotion ID"
+//	@Param			payload	body		GrantPromotionPayload	true	"User"
+//	@Success		201		{object}	models.PromotionGrant
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		409		{object}	map[string]string	"message"
+//	@Failure		422		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/promotions/{id}/grants [post]
+func GrantPromotion(c *gin.Context) {
+	id, ok := parsePromotionID(c)
+	if !ok {
+		return
+	}
+	var payload GrantPromotionPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	adminID, _ := c.Get("user_id")
+	grantedBy := adminID.(uint)
+	grant, err := services.GrantPromotion(database.DB, id, payload.UserID, &grantedBy)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, grant)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "Promotion not found"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+	case errors.Is(err, services.ErrPromotionAlreadyGranted):
+		c.JSON(http.StatusConflict, gin.H{"message": "Promotion already granted to this user"})
+	case errors.Is(err, services.ErrPromotionInactive), errors.Is(err, services.ErrPromotionBudgetExhausted),
+		errors.Is(err, services.ErrPromotionNotEligible):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to grant promotion"})
+	}
+}
+
+// GetPromotionGrants lists who received a campaign
+//
+//	@Summary		getPromotionGrants
+//	@Description	Lists a campaign's grants, newest first
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Promotion ID"
+//	@Success		200	{object}	[]models.PromotionGrant
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/promotions/{id}/grants [get]
+func GetPromotionGrants(c *gin.Context) {
+	id, ok := parsePromotionID(c)
+	if !ok {
+		return
+	}
+	var grants []models.PromotionGrant
+	if err := database.DB.Where("promotion_id = ?", id).Order("id DESC").Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch grants"})
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// GetPromotionSpend reports what each campaign has paid out
+//
+//	@Summary		getPromotionSpend
+//	@Description	Grants and amount paid per campaign between two dates (both optional, end inclusive), with the budget each has left
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			start_date	query		string	false	"Start Date : '2024-06-01'"
+//	@Param			end_date	query		string	false	"End Date : '2024-06-30'"
+//	@Success		200			{object}	[]services.PromotionSpend
+//	@Failure		400			{object}	map[string]string	"message"
+//	@Failure		403			{object}	map[string]string	"message"
+//	@Failure		500			{object}	map[string]string	"message"
+//	@Router			/admin/promotions/spend [get]
+func GetPromotionSpend(c *gin.Context) {
+	var from, to time.Time
+	if value := c.Query("start_date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid start_date"})
+			return
+		}
+		from = parsed
+	}
+	if value := c.Query("end_date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid end_date"})
+			return
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	report, err := services.PromotionSpendReport(database.DB, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/services"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrImageSize is the width and height of generated QR PNGs in pixels
+const qrImageSize = 256
+
+// QRResponse carries the EMVCo payload and its rendered PNG
+type QRResponse struct {
+	AccountNumber string  `json:"account_number"`
+	Amount        float64 `json:"amount,omitempty"`
+	Payload       string  `json:"payload"`
+	// PNG is the base64-encoded QR image
+	PNG []byte `json:"png" swaggertype:"string" format:"base64"`
+}
+
+// DecodeQRPayload is used to bind a scanned QR payload
+type DecodeQRPayload struct {
+	Payload string `json:"payload" binding:"required"`
+}
+
+// GetQR builds a PromptPay-style QR for the logged-in user's account
+//
+//	@Summary		getQR
+//	@Description	Builds an EMVCo QR payload (with CRC16) and PNG for the caller's account. Use format=png to receive the image directly
+//	@Tags			accounting
+//	@Security		BearerAuth
+//	@Produce		json,png
+//	@Param			amount	query		number	false	"Fixed amount to request"
+//	@Param			format	query		string	false	"json (default) or png"
+//	@Success		200		{object}	QRResponse
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Failure		404		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/accounting/qr [get]
+func GetQR(c *gin.Context) {
+	idparam, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "User not logged in"})
+		return
+	}
+	userID, ok := idparam.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Invalid user ID"})
+		return
+	}
+	var amount float64
+	if raw := c.Query("amount"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid amount"})
+			return
+		}
+		amount = parsed
+	}
+	user, err := GetDataUser(userID)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	payload, err := services.BuildQRPayload(user.AccountNumber, amount)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Amount is too large for a QR code"})
+		return
+	}
+	png, err := qrcode.Encode(payload, qrcode.Medium, qrImageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Could not render QR code"})
+		return
+	}
+	if c.Query("format") == "png" {
+		c.Data(http.StatusOK, "image/png", png)
+		return
+	}
+	c.JSON(http.StatusOK, QRResponse{
+		AccountNumber: user.AccountNumber,
+		Amount:        amount,
+		Payload:       payload,
+		PNG:           png,
+	})
+}
+
+// DecodeQR turns a scanned QR payload into a prefilled transfer request
+//
+//	@Summary		decodeQR
+//	@Description	Verifies a scanned EMVCo payload and returns a prefilled transferRequest
+//	@Tags			accounting
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		DecodeQRPayload	true	"Scanned QR payload"
+//	@Success		200		{object}	transferRequest
+//	@Failure		400		{object}	map[string]string	"message"
+//	@Failure		401		{object}	map[string]string	"message"
+//	@Router			/accounting/qr/decode [post]
+func DecodeQR(c *gin.Context) {
+	var payload DecodeQRPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	payment, err := services.ParseQRPayload(payload.Payload)
+	if err != nil {
+		if errors.Is(err, services.ErrQRChecksum) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "QR checksum mismatch"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid QR payload"})
+		return
+	}
+	c.JSON(http.StatusOK, transferRequest{
+		ReceiverAccount: payment.AccountNumber,
+		Amount:          payment.Amount,
+	})
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"gotestbackend/database"
+	"gotestbackend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondReconciliation writes a report as JSON, or as CSV when format=csv
+func respondReconciliation(c *gin.Context, report services.ReconciliationReport) {
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="reconciliation.csv"`)
+	c.Header("Content-Type", "text/csv")
+	c.Status(http.StatusOK)
+	if err := services.WriteReconciliationCSV(c.Writer, report); err != nil {
+		c.Error(err)
+	}
+}
+
+// GetReconciliation replays every account and reports balance discrepancies
+//
+//	@Summary		getReconciliation
+//	@Description	Replays each account's transactions from its opening balance and reports where remaining snapshots or Credit disagree
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json,text/csv
+//	@Param			format	query		string	false	"json (default) or csv"
+//	@Success		200		{object}	services.ReconciliationReport
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/reconciliation [get]
+func GetReconciliation(c *gin.Context) {
+	report, err := services.Reconcile(database.DB, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Reconciliation failed"})
+		return
+	}
+	respondReconciliation(c, report)
+}
+
+// CorrectReconciliation reconciles and writes adjusting entries for credit mismatches
+//
+//	@Summary		correctReconciliation
+//	@Description	Runs reconciliation and posts an adjustment line against the suspense account for every credit mismatch
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json,text/csv
+//	@Param			format	query		string	false	"json (default) or csv"
+//	@Success		200		{object}	services.ReconciliationReport
+//	@Failure		403		{object}	map[string]string	"message"
+//	@Failure		500		{object}	map[string]string	"message"
+//	@Router			/admin/reconciliation/corrections [post]
+func CorrectReconciliation(c *gin.Context) {
+	report, err := services.Reconcile(database.DB, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Reconciliation failed", "corrections": report.Corrections})
+		return
+	}
+	respondReconciliation(c, report)
+}
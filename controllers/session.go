@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"errors"
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionView is a session as its owner sees it
+type SessionView struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// GetSessions lists where the logged-in user is signed in
+//
+//	@Summary		getSessions
+//	@Description	Lists the active sessions of the logged-in user, most recently used first. The one making the request is marked current
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	[]SessionView
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/sessions [get]
+func GetSessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	currentID, _ := c.Get("session_id")
+	sessions, err := services.ActiveSessions(database.DB, userID.(uint), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch sessions"})
+		return
+	}
+	views := make([]SessionView, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, SessionView{Session: session, Current: session.ID == currentID})
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// RevokeSession signs out one of the logged-in user's sessions
+//
+//	@Summary		revokeSession
+//	@Description	Signs out one session of the logged-in user; its token stops working straight away
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		string	true	"Session ID"
+//	@Success		200	{object}	map[string]string	"message"
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/sessions/{id} [delete]
+func RevokeSession(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid session ID"})
+		return
+	}
+	if err := services.RevokeSession(database.DB, userID.(uint), uint(id)); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to revoke session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions signs out everywhere except the current session
+//
+//	@Summary		revokeOtherSessions
+//	@Description	Logs out everywhere else: every session of the logged-in user except the one making the request
+//	@Tags			User
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	map[string]int64	"revoked"
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/user/me/sessions/revoke-others [post]
+func RevokeOtherSessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	currentID, _ := c.Get("session_id")
+	revoked, err := services.RevokeSessions(database.DB, userID.(uint), currentID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to revoke sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// asUser returns a handler chain that runs handler as if userID were logged in
+func asUser(userID uint, handler gin.HandlerFunc) []gin.HandlerFunc {
+	return []gin.HandlerFunc{func(c *gin.Context) { c.Set("user_id", userID) }, handler}
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatementPublicKeyResponse is the key signed statements are verified with
+type StatementPublicKeyResponse struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	// PublicKey is the raw Ed25519 public key, base64 encoded
+	PublicKey string `json:"public_key"`
+}
+
+// GetStatement downloads a signed statement of the logged-in user's account
+//
+//	@Summary		getStatement
+//	@Description	Lists the account's transactions between two dates, signed with the server's statement key so it can be verified offline against /accounting/statement/public-key. Defaults to this month. API keys need read:transfers
+//	@Tags			accounting
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			start_date	query		string	false	"Start Date : '2024-06-01'"
+//	@Param			end_date	query		string	false	"End Date : '2024-06-30'"
+//	@Success		200			{object}	services.SignedStatement
+//	@Failure		400			{object}	map[string]string	"message"
+//	@Failure		401			{object}	map[string]string	"message"
+//	@Failure		404			{object}	map[string]string	"message"
+//	@Failure		500			{object}	map[string]string	"message"
+//	@Router			/accounting/statement [get]
+func GetStatement(c *gin.Context) {
+	if !requireScope(c, models.ScopeReadTransfers) {
+		return
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "User not logged in"})
+		return
+	}
+	user, err := GetDataUser(userID.(uint))
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := now
+	for param, date := range map[string]*time.Time{"start_date": &from, "end_date": &to} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid " + param})
+			return
+		}
+		*date = parsed
+	}
+	statement, err := services.BuildStatement(database.DB, user, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDateRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "end_date is before start_date"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build statement"})
+		return
+	}
+	signed, err := services.SignStatement(statement)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to sign statement"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"statement-%s-%s-%s.json\"", statement.AccountNumber, statement.From, statement.To))
+	c.JSON(http.StatusOK, signed)
+}
+
+// GetStatementPublicKey returns the key statements are signed with
+//
+//	@Summary		getStatementPublicKey
+//	@Description	Public key for verifying downloaded statements, e.g. with go run ./cmd/statementverify
+//	@Tags			accounting
+//	@Produce		json
+//	@Success		200	{object}	StatementPublicKeyResponse
+//	@Failure		503	{object}	map[string]string	"message"
+//	@Router			/accounting/statement/public-key [get]
+func GetStatementPublicKey(c *gin.Context) {
+	key := services.StatementPublicKey()
+	if key == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "Statement signing is not configured"})
+		return
+	}
+	c.JSON(http.StatusOK, StatementPublicKeyResponse{
+		Algorithm: services.StatementAlgorithm,
+		KeyID:     services.StatementKeyID(key),
+		PublicKey: base64.StdEncoding.EncodeToString(key),
+	})
+}
+
+// VerifyLedger checks the logged-in user's transaction chain
+//
+//	@Summary		verifyLedger
+//	@Description	Recomputes the hash of every transaction on the account and checks each links to the one before it and the chain ends at the stored head
+//	@Tags			accounting
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	services.LedgerVerification
+//	@Failure		401	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/accounting/ledger/verify [get]
+func VerifyLedger(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "User not logged in"})
+		return
+	}
+	respondLedgerVerification(c, userID.(uint))
+}
+
+// VerifyUserLedger checks any account's transaction chain
+//
+//	@Summary		verifyUserLedger
+//	@Description	Recomputes the hash of every transaction on the account and checks each links to the one before it and the chain ends at the stored head
+//	@Tags			admin
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"User ID"
+//	@Success		200	{object}	services.LedgerVerification
+//	@Failure		400	{object}	map[string]string	"message"
+//	@Failure		403	{object}	map[string]string	"message"
+//	@Failure		404	{object}	map[string]string	"message"
+//	@Failure		500	{object}	map[string]string	"message"
+//	@Router			/admin/users/{id}/ledger/verify [get]
+func VerifyUserLedger(c *gin.Context) {
+	id, ok := parseUserID(c)
+	if !ok {
+		return
+	}
+	respondLedgerVerification(c, id)
+}
+
+// respondLedgerVerification writes the chain check of one account
+func respondLedgerVerification(c *gin.Context, userID uint) {
+	result, err := services.VerifyLedgerChain(database.DB, userID)
+	if err != nil {
+		respondUserLookupError(c, err, "User not found")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
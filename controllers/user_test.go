@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateUserAuditsNamesAndPassword(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.CreateUser(t, db, "hana", "123456789", 0)
+	if err := services.SetPassword(db, &user, "Old-Secret-5521x"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.PATCH("/user/me", asUser(user.ID, UpdateUser)...)
+	body, _ := json.Marshal(map[string]string{"first_name": "Hanna", "password": "New-Secret-8834x", "current_password": "Old-Secret-5521x"})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/user/me", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "$2a$") {
+		t.Errorf("response leaks the password hash: %s", w.Body)
+	}
+
+	var entry models.AuditEntry
+	if err := db.Where("action = ?", services.AuditProfileUpdate).Last(&entry).Error; err != nil {
+		t.Fatalf("no profile audit entry: %v", err)
+	}
+	var details map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(entry.Details), &details); err != nil {
+		t.Fatalf("details %q: %v", entry.Details, err)
+	}
+	if got := details["first_name"]; got["from"] != "Test" || got["to"] != "Hanna" {
+		t.Errorf("first_name diff = %v, want Test -> Hanna", got)
+	}
+	if got := details["password"]; got["changed"] != true || len(got) != 1 {
+		t.Errorf("password diff = %v, want only changed: true", got)
+	}
+}
+
+func TestUpdateUserByIDOnlyChangesNames(t *testing.T) {
+	db := testutil.NewDB(t)
+	admin := testutil.CreateUser(t, db, "admin1", "111111111", 0)
+	user := testutil.CreateUser(t, db, "ines", "222222222", 250)
+
+	r := gin.New()
+	r.PUT("/admin/users/:id", asUser(admin.ID, UpdateUserByID)...)
+	body, _ := json.Marshal(map[string]interface{}{
+		"first_name":   "Inez",
+		"deleted_at":   "2024-01-01T00:00:00Z",
+		"account_type": models.AccountTypeBusiness,
+		"credit":       1e6,
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/admin/users/"+fmt.Sprint(user.ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", w.Code, w.Body)
+	}
+
+	var stored models.User
+	if err := db.First(&stored, user.ID).Error; err != nil {
+		t.Fatalf("user is gone after the update: %v", err)
+	}
+	if stored.FirstName != "Inez" {
+		t.Errorf("first name = %q, want Inez", stored.FirstName)
+	}
+	if stored.Credit != 250 || stored.AccountType != user.AccountType {
+		t.Errorf("credit %.2f, account type %q changed; want 250.00 and %q", stored.Credit, stored.AccountType, user.AccountType)
+	}
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"log"
+
+	"gotestbackend/models"
+	"gotestbackend/utils"
+
+	"gorm.io/gorm"
+)
+
+// grandfatherAccountNumbers flags users whose account numbers predate check digits,
+// so they keep working after validation starts checking the last digit.
+func grandfatherAccountNumbers(db *gorm.DB) {
+	var users []models.User
+	if err := db.Where("legacy_account_number = ?", false).Find(&users).Error; err != nil {
+		log.Fatalf("Failed to load users for account number migration: %v", err)
+	}
+	for _, user := range users {
+		if utils.HasValidCheckDigit(user.AccountNumber) {
+			continue
+		}
+		if err := db.Model(&user).Update("legacy_account_number", true).Error; err != nil {
+			log.Fatalf("Failed to flag legacy account number %s: %v", user.AccountNumber, err)
+		}
+		log.Printf("Grandfathered legacy account number %s", user.AccountNumber)
+	}
+}
@@ -14,6 +14,8 @@ func SetupDB() *gorm.DB {
 	dsn := "thanakrit:neung7989@tcp(127.0.0.1:3306)/gin_rest_api?charset=utf8mb4&parseTime=True&loc=Local"
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// Unique index violations come back as gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 
 	if err != nil {
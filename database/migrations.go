@@ -1,7 +1,9 @@
 package database
 
 import (
+	"fmt"
 	"log"
+	"time"
 
 	"gotestbackend/models"
 
@@ -9,11 +11,85 @@ import (
 )
 
 func Migrate(db *gorm.DB) {
-	err := db.AutoMigrate(&models.User{}, &models.Transaction{})
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		log.Fatalf("Error migrating database: %v", err)
+	}
+	backfillOpeningBalances(db)
+	// Foreign keys on transactions need every sender and receiver to exist
+	repairOrphanTransactions(db)
+	err := db.AutoMigrate(&models.Transaction{}, &models.AccountSequence{}, &models.AccountNumberReservation{}, &models.AccountStatusChange{}, &models.OverdraftLimitChange{},
+		&models.FeeSchedule{}, &models.FeeTier{}, &models.InterestProduct{}, &models.InterestAccrual{},
+		&models.FraudReview{}, &models.TransactionPIN{},
+		&models.MFAEnrollment{}, &models.MFARecoveryCode{},
+		&models.LoginThrottle{}, &models.PasswordResetToken{},
+		&models.Session{}, &models.APIKey{},
+		&models.ExternalIdentity{}, &models.OIDCLoginState{},
+		&models.ContactVerification{}, &models.Alias{},
+		&models.AuditEntry{}, &models.AuditChainHead{},
+		&models.BalanceAdjustment{}, &models.BalanceAdjustmentDecision{},
+		&models.Payment{}, &models.Promotion{}, &models.PromotionGrant{},
+		&models.RedeemedQuote{}, &models.LedgerChainHead{})
 	if err != nil {
 		log.Fatalf("Error migrating database: %v", err)
 	}
+	// Aliases used to copy the owner's account number; they resolve through the owner now
+	if db.Migrator().HasColumn(&models.Alias{}, "account_number") {
+		if err := db.Migrator().DropColumn(&models.Alias{}, "account_number"); err != nil {
+			log.Fatalf("Error dropping aliases.account_number: %v", err)
+		}
+	}
 	InsertSampleUser()
+	ensureSystemAccounts(db)
+	ensureDefaultPromotion(db)
+	grandfatherAccountNumbers(db)
+	// Start the dormancy clock for accounts created before activity was tracked
+	if err := db.Model(&models.User{}).Where("last_activity_at IS NULL").Update("last_activity_at", time.Now()).Error; err != nil {
+		log.Fatalf("Error setting last activity: %v", err)
+	}
 	//InsertSampleTransaction()
 	log.Println("Database migration completed.")
 }
+
+// backfillOpeningBalances records the credit accounts opened before sign-up
+// promotions started with, so reconciliation can still replay them. House
+// accounts always opened empty.
+func backfillOpeningBalances(db *gorm.DB) {
+	err := db.Unscoped().Model(&models.User{}).Where("opening_balance IS NULL AND role <> ?", models.RoleSystem).
+		Update("opening_balance", models.LegacyOpeningBalance).Error
+	if err == nil {
+		err = db.Unscoped().Model(&models.User{}).Where("opening_balance IS NULL").Update("opening_balance", 0).Error
+	}
+	if err != nil {
+		log.Fatalf("Error backfilling opening balances: %v", err)
+	}
+}
+
+// repairOrphanTransactions recreates users that were hard-deleted before soft deletion existed
+// as closed, soft-deleted tombstones, so old transactions still resolve.
+func repairOrphanTransactions(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.Transaction{}) {
+		return
+	}
+	var orphanIDs []uint
+	err := db.Raw(`SELECT DISTINCT t.id FROM (
+			SELECT sender_id AS id FROM transactions UNION SELECT receiver_id AS id FROM transactions
+		) t LEFT JOIN users u ON u.id = t.id WHERE u.id IS NULL AND t.id > 0`).Scan(&orphanIDs).Error
+	if err != nil {
+		log.Fatalf("Error looking for orphan transactions: %v", err)
+	}
+	now := time.Now()
+	for _, id := range orphanIDs {
+		tombstone := models.User{
+			ID:              id,
+			Username:        fmt.Sprintf("deleted-user-%d", id),
+			Status:          models.AccountClosed,
+			StatusReason:    "Hard-deleted before soft deletion was introduced",
+			StatusChangedAt: &now,
+			DeletedAt:       gorm.DeletedAt{Time: now, Valid: true},
+		}
+		if err := db.Create(&tombstone).Error; err != nil {
+			log.Fatalf("Error recreating deleted user %d: %v", id, err)
+		}
+		log.Printf("Recreated hard-deleted user %d as a tombstone", id)
+	}
+}
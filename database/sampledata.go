@@ -3,6 +3,7 @@ package database
 import (
 	"gotestbackend/models"
 	"log"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -31,9 +32,10 @@ func InsertSampleUser() {
 		{Username: "user10", Password: hashPassword("password10"), FirstName: "Henry", LastName: "Lee", AccountNumber: "1010101010"},
 	}
 
+	// Sample users open empty and, like everyone else, get the sign-up promotion once they verify a contact
+	now := time.Now()
 	for _, user := range users {
-		// Set default credit value
-		user.Credit = 1000
+		user.OpenedAt = &now
 		if err := DB.Create(&user).Error; err != nil {
 			log.Printf("Could not insert user %s: %v", user.Username, err)
 		}
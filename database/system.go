@@ -0,0 +1,89 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"gotestbackend/models"
+	"gotestbackend/utils"
+
+	"gorm.io/gorm"
+)
+
+// systemAccountPrefix starts the account numbers of house accounts, away from customer prefixes
+const systemAccountPrefix = "99"
+
+// systemAccountNumber builds the check-digited account number of the n-th house account
+func systemAccountNumber(n int) string {
+	base := fmt.Sprintf("%s%07d", systemAccountPrefix, n)
+	return base + string(utils.LuhnCheckDigit(base))
+}
+
+// ensureSystemAccount creates a house account with zero credit unless it
+// already exists, and restores it if it was soft-deleted
+func ensureSystemAccount(db *gorm.DB, username, name string, n int) {
+	var user models.User
+	err := db.Unscoped().Where("username = ?", username).First(&user).Error
+	if err == nil {
+		if user.Role != models.RoleSystem {
+			log.Fatalf("Username %s is reserved for a system account but belongs to user %d", username, user.ID)
+		}
+		if user.DeletedAt.Valid {
+			if err := db.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
+				log.Fatalf("Could not restore system account %s: %v", username, err)
+			}
+			log.Printf("Restored soft-deleted system account %s (%s)", username, user.AccountNumber)
+		}
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Fatalf("Failed to look up system account %s: %v", username, err)
+	}
+	user = models.User{
+		Username:      username,
+		FirstName:     name,
+		LastName:      "Account",
+		AccountNumber: systemAccountNumber(n),
+		Role:          models.RoleSystem,
+		Status:        models.AccountActive,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		log.Fatalf("Could not create system account %s: %v", username, err)
+	}
+	log.Printf("Created system account %s (%s)", username, user.AccountNumber)
+}
+
+// ensureDefaultPromotion replaces the credit accounts used to be opened with by
+// a sign-up campaign of the same amount, until an admin sets up promotions.
+// Like every sign-up campaign it only pays users with a verified contact.
+func ensureDefaultPromotion(db *gorm.DB) {
+	var count int64
+	if err := db.Model(&models.Promotion{}).Count(&count).Error; err != nil {
+		log.Fatalf("Failed to count promotions: %v", err)
+	}
+	if count > 0 {
+		return
+	}
+	promotion := models.Promotion{
+		Name:      "Welcome bonus",
+		Kind:      models.PromotionSignup,
+		Amount:    models.LegacyOpeningBalance,
+		BudgetCap: 1000 * models.LegacyOpeningBalance,
+		Active:    true,
+	}
+	if err := db.Create(&promotion).Error; err != nil {
+		log.Fatalf("Could not create default promotion: %v", err)
+	}
+	log.Printf("Created sign-up promotion %q", promotion.Name)
+}
+
+// ensureSystemAccounts creates every house account the ledger posts against
+func ensureSystemAccounts(db *gorm.DB) {
+	ensureSystemAccount(db, models.SystemAccountFees, "Fee Income", 1)
+	ensureSystemAccount(db, models.SystemAccountInterest, "Interest Expense", 2)
+	ensureSystemAccount(db, models.SystemAccountSuspense, "Suspense", 3)
+	ensureSystemAccount(db, models.SystemAccountAdjustments, "Adjustments", 4)
+	ensureSystemAccount(db, models.SystemAccountSettlement, "Settlement", 5)
+	ensureSystemAccount(db, models.SystemAccountPromotions, "Promotions", 6)
+}
@@ -0,0 +1,74 @@
+// Package testutil holds the fixtures tests share: a throwaway database with
+// every migration applied, and factories for the rows most tests start from.
+package testutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"gotestbackend/utils"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NewDB points database.DB at a fresh in-memory SQLite database with every
+// migration applied and sends notifications to a temporary outbox. Both are
+// put back when the test ends.
+func NewDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	previous, previousNotify := database.DB, services.Notify
+	database.DB = db
+	services.Notify = services.FileNotifier{Dir: t.TempDir()}
+	t.Cleanup(func() {
+		database.DB, services.Notify = previous, previousNotify
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	database.Migrate(db)
+	return db
+}
+
+// CreateUser saves an active customer with credit and a valid account number built from base
+func CreateUser(t *testing.T, db *gorm.DB, username, base string, credit float64) models.User {
+	t.Helper()
+	now := time.Now()
+	user := models.User{
+		Username:      username,
+		FirstName:     "Test",
+		LastName:      "User",
+		AccountNumber: base + string(utils.LuhnCheckDigit(base)),
+		Credit:        credit,
+		Status:        models.AccountActive,
+		OpenedAt:      &now,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// Credit reads a user's stored credit
+func Credit(t *testing.T, db *gorm.DB, userID uint) float64 {
+	t.Helper()
+	var user models.User
+	if err := db.Unscoped().First(&user, userID).Error; err != nil {
+		t.Fatalf("load user %d: %v", userID, err)
+	}
+	return user.Credit
+}
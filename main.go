@@ -4,6 +4,9 @@ import (
 	"gotestbackend/controllers"
 	"gotestbackend/database"
 	"gotestbackend/middlewares"
+	"gotestbackend/services"
+	"log"
+	"time"
 
 	//"gotestbackend/middlewares"
 
@@ -31,37 +34,141 @@ var (
 //	@name						Authorization
 
 func main() {
+	configureStatementKey()
+	configureAccountNumbers()
+	configureDormancy()
+	configureOverdraft()
+	configurePIN()
+	configurePasswordPolicy()
+	configureContacts()
+	configureAdjustments()
+	configureFraudRules()
 
 	// Run migrations
 	database.Migrate(db)
+	if sealed, err := services.SealTransactions(db); err != nil {
+		log.Fatalf("Error hashing transactions: %v", err)
+	} else if sealed > 0 {
+		log.Printf("Hashed %d transactions into their account chains", sealed)
+	}
+	configureBootstrapAdmin(db)
+	if granted, err := services.GrantMissedSignupPromotions(db); err != nil {
+		log.Printf("Could not grant missed sign-up promotions: %v", err)
+	} else if granted > 0 {
+		log.Printf("Granted %d missed sign-up promotions", granted)
+	}
+	services.StartDormancyJob(db, 24*time.Hour)
+	services.StartOverdraftJob(db, 24*time.Hour)
+	services.StartInterestJob(db, 24*time.Hour)
+	configurePaymentRails()
+	configureOIDCProviders()
 
 	r := gin.Default()
+	configureTrustedProxies(r)
+	r.Use(middlewares.RequestIDMiddleware())
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	// Routes
 	v := r.Group("/api")
 	{
-		//CRUD
-		v.GET("/userAll", controllers.GetAllUser)
 		//5.
 		v.POST("/user/register", controllers.Register)
-		v.GET("/user/GetUserByID/:id", controllers.GetUserByID)
-		v.PUT("/user/UpdateUserByID/:id", controllers.UpdateUserByID)
-		v.DELETE("/user/DeleteUserByID/:id", controllers.DeleteUserByID)
 		//CRUD
 		//6.
 		v.POST("/user/login", controllers.Login) //
+		v.POST("/user/login/mfa", controllers.LoginMFA)
+		v.POST("/user/password/forgot", controllers.ForgotPassword)
+		v.POST("/user/password/reset", controllers.ResetPassword)
+		v.GET("/auth/oidc/:provider/login", controllers.StartOIDCLogin)
+		v.GET("/auth/oidc/:provider/callback", controllers.OIDCCallback)
+		v.GET("/accounting/statement/public-key", controllers.GetStatementPublicKey)
+		v.POST("/payments/callbacks/:rail", controllers.PaymentCallback)
 		//v1.Use(middlewares.AuthMiddleware())
 	}
 	v1 := r.Group("/api").Use(middlewares.JWTAuthMiddleware())
 	{
-		//7.
-		v1.GET("/user/me", controllers.GetUser) //
 		//8.
 		v1.PATCH("/user/me", controllers.UpdateUser)
+		v1.GET("/user/me/pin", controllers.GetPINStatus)
+		v1.PUT("/user/me/pin", controllers.SetPIN)
+		v1.POST("/user/me/mfa", controllers.EnrollMFA)
+		v1.POST("/user/me/mfa/confirm", controllers.ConfirmMFA)
+		v1.GET("/user/me/sessions", controllers.GetSessions)
+		v1.DELETE("/user/me/sessions/:id", controllers.RevokeSession)
+		v1.POST("/user/me/sessions/revoke-others", controllers.RevokeOtherSessions)
+		v1.GET("/accounting/qr", controllers.GetQR)
+		v1.POST("/accounting/qr/decode", controllers.DecodeQR)
+		v1.POST("/user/me/api-keys", controllers.CreateAPIKey)
+		v1.GET("/user/me/api-keys", controllers.GetAPIKeys)
+		v1.DELETE("/user/me/api-keys/:id", controllers.RevokeAPIKey)
+		v1.GET("/user/me/identities", controllers.GetIdentities)
+		v1.POST("/user/me/identities/:provider", controllers.LinkIdentity)
+		v1.DELETE("/user/me/identities/:id", controllers.UnlinkIdentity)
+		v1.POST("/user/me/contacts/:channel/code", controllers.SendContactCode)
+		v1.POST("/user/me/contacts/:channel/verify", controllers.VerifyContact)
+		v1.GET("/user/me/aliases", controllers.GetAliases)
+		v1.POST("/user/me/aliases", controllers.CreateAlias)
+		v1.DELETE("/user/me/aliases/:id", controllers.DeleteAlias)
+		v1.GET("/accounting/ledger/verify", controllers.VerifyLedger)
+		v1.POST("/accounting/deposits", controllers.Deposit)
+		v1.POST("/accounting/withdrawals", controllers.Withdraw)
+		v1.GET("/accounting/payments", controllers.GetPayments)
+		v1.GET("/accounting/payments/:id", controllers.GetPayment)
+	}
+	// Routes API keys may call, each checking its scope
+	keyed := r.Group("/api").Use(middlewares.JWTOrAPIKeyMiddleware())
+	{
+		//7.
+		keyed.GET("/user/me", controllers.GetUser) //
 		//9.
-		v1.POST("/accounting/transfer", controllers.Transfer)
+		keyed.POST("/accounting/transfer", controllers.Transfer)
+		keyed.GET("/accounting/transfer/inquiry", controllers.TransferInquiry)
 		//10.
-		v1.GET("/accounting/transfer-list", controllers.GetTransferList)
+		keyed.GET("/accounting/transfer-list", controllers.GetTransferList)
+		keyed.GET("/accounting/statement", controllers.GetStatement)
+	}
+	admin := r.Group("/api/admin").Use(middlewares.JWTAuthMiddleware(), middlewares.AdminMiddleware(), middlewares.AuditAdminMiddleware())
+	{
+		admin.POST("/account-numbers/reservations", controllers.ReserveAccountNumbers)
+		admin.GET("/account-numbers/reservations", controllers.GetAccountNumberReservations)
+		admin.DELETE("/account-numbers/reservations/:id", controllers.DeleteAccountNumberReservation)
+		admin.GET("/users", controllers.GetAllUser)
+		admin.GET("/users/:id", controllers.GetUserByID)
+		admin.PUT("/users/:id/status", controllers.UpdateAccountStatus)
+		admin.GET("/users/:id/status-history", controllers.GetAccountStatusHistory)
+		admin.PUT("/users/:id", controllers.UpdateUserByID)
+		admin.DELETE("/users/:id", controllers.DeleteUserByID)
+		admin.POST("/users/:id/restore", controllers.RestoreUser)
+		admin.POST("/users/:id/api-keys", controllers.CreateUserAPIKey)
+		admin.PUT("/users/:id/overdraft-limit", controllers.SetOverdraftLimit)
+		admin.GET("/users/:id/overdraft-limit/history", controllers.GetOverdraftLimitHistory)
+		admin.POST("/fee-schedules", controllers.CreateFeeSchedule)
+		admin.GET("/fee-schedules", controllers.GetFeeSchedules)
+		admin.DELETE("/fee-schedules/:id", controllers.DeactivateFeeSchedule)
+		admin.POST("/interest-products", controllers.CreateInterestProduct)
+		admin.GET("/interest-products", controllers.GetInterestProducts)
+		admin.PUT("/users/:id/interest-product", controllers.SetInterestProduct)
+		admin.GET("/users/:id/interest-accruals", controllers.GetInterestAccruals)
+		admin.POST("/interest/run", controllers.RunInterest)
+		admin.GET("/reconciliation", controllers.GetReconciliation)
+		admin.POST("/reconciliation/corrections", controllers.CorrectReconciliation)
+		admin.GET("/fraud/reviews", controllers.GetFraudReviews)
+		admin.POST("/fraud/reviews/:id/approve", controllers.ApproveFraudReview)
+		admin.POST("/fraud/reviews/:id/reject", controllers.RejectFraudReview)
+		admin.GET("/login-lockouts", controllers.GetLoginLockouts)
+		admin.DELETE("/login-lockouts/:id", controllers.ClearLoginLockout)
+		admin.GET("/audit", controllers.GetAuditLog)
+		admin.GET("/users/:id/ledger/verify", controllers.VerifyUserLedger)
+		admin.POST("/users/:id/adjustments", controllers.RequestAdjustment)
+		admin.GET("/adjustments", controllers.GetAdjustments)
+		admin.GET("/adjustments/:id", controllers.GetAdjustment)
+		admin.POST("/adjustments/:id/approve", controllers.ApproveAdjustment)
+		admin.POST("/adjustments/:id/reject", controllers.RejectAdjustment)
+		admin.POST("/promotions", controllers.CreatePromotion)
+		admin.GET("/promotions", controllers.GetPromotions)
+		admin.GET("/promotions/spend", controllers.GetPromotionSpend)
+		admin.DELETE("/promotions/:id", controllers.DeactivatePromotion)
+		admin.POST("/promotions/:id/grants", controllers.GrantPromotion)
+		admin.GET("/promotions/:id/grants", controllers.GetPromotionGrants)
 	}
 
 	// Swagger route
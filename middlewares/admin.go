@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"gotestbackend/database"
+	"gotestbackend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware only lets through users with the admin role whose session
+// passed two-factor authentication. Enrolling does not upgrade a session that
+// was issued before; the admin has to log in again.
+// It must run after JWTAuthMiddleware, which sets user_id and mfa_verified.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		var user models.User
+		if err := database.DB.First(&user, userID).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if user.Role != models.RoleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+		var enrolled int64
+		if err := database.DB.Model(&models.MFAEnrollment{}).Where("user_id = ? AND confirmed_at IS NOT NULL", user.ID).Count(&enrolled).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor enrolment"})
+			return
+		}
+		if enrolled == 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admins must enrol in two-factor authentication"})
+			return
+		}
+		if !c.GetBool("mfa_verified") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Log in again with two-factor authentication to use admin routes"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWTOrAPIKeyMiddleware accepts either "Bearer {token}" or "ApiKey {key}".
+// An API key sets user_id and scopes; handlers must check the scope they need.
+// A Bearer token behaves as in JWTAuthMiddleware and sets no scopes.
+func JWTOrAPIKeyMiddleware() gin.HandlerFunc {
+	jwtAuth := JWTAuthMiddleware()
+	return func(c *gin.Context) {
+		key, isAPIKey := strings.CutPrefix(c.GetHeader("Authorization"), "ApiKey ")
+		if !isAPIKey {
+			jwtAuth(c)
+			return
+		}
+		// ClientIP only honours X-Forwarded-For from TRUSTED_PROXIES, so the
+		// allowlist cannot be satisfied by a forged header
+		record, err := services.AuthenticateAPIKey(database.DB, strings.TrimSpace(key), c.ClientIP(), time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+		// Keys of deleted users stop working with them
+		var user models.User
+		if err := database.DB.Select("id").First(&user, record.UserID).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+		c.Set("user_id", record.UserID)
+		c.Set("api_key_id", record.ID)
+		c.Set("scopes", services.APIKeyScopeList(record))
+		c.Next()
+	}
+}
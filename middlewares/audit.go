@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"gotestbackend/database"
+	"gotestbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in and out
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware tags every request with an ID, reusing a sane one sent by
+// the client, so audit entries can be matched to proxy and access logs.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 64 {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// AuditAdminMiddleware writes an audit entry for every admin request that
+// changes something, once the handler has answered.
+// It must run after AdminMiddleware, which sets user_id.
+func AuditAdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			return
+		}
+		var actor *uint
+		if id, ok := c.Get("user_id"); ok {
+			if userID, ok := id.(uint); ok {
+				actor = &userID
+			}
+		}
+		_, err := services.RecordAudit(database.DB, services.AuditEvent{
+			Action:     services.AuditAdminRequest,
+			ActorID:    actor,
+			TargetType: "route",
+			TargetID:   c.Param("id"),
+			IP:         c.ClientIP(),
+			RequestID:  c.GetString("request_id"),
+			Details: map[string]interface{}{
+				"method": c.Request.Method,
+				"route":  c.FullPath(),
+				"path":   c.Request.URL.Path,
+				"status": c.Writer.Status(),
+			},
+		})
+		if err != nil {
+			log.Printf("Audit: could not record admin request %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		}
+	}
+}
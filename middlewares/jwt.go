@@ -5,6 +5,9 @@ import (
 	"strings"
 	"time"
 
+	"gotestbackend/database"
+	"gotestbackend/models"
+
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	//"github.com/hexops/valast"
@@ -31,9 +34,32 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// Tokens issued before the last password change are revoked
+		var user models.User
+		if err := database.DB.Select("id", "password_changed_at").First(&user, claims.UserID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+		if user.PasswordChangedAt != nil && claims.IssuedAt < user.PasswordChangedAt.Unix() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked, please log in again"})
+			c.Abort()
+			return
+		}
+		var session models.Session
+		if err := database.DB.Where("id = ? AND user_id = ?", claims.SessionID, claims.UserID).First(&session).Error; err != nil || session.RevokedAt != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked, please log in again"})
+			c.Abort()
+			return
+		}
+		if now := time.Now(); now.Sub(session.LastSeenAt) > sessionSeenInterval {
+			database.DB.Model(&session).Update("last_seen_at", now)
+		}
 		// Set user ID to context
 		//fmt.Println("JWTAuthMiddleware userID=", claims.UserID)
 		c.Set("user_id", claims.UserID)
+		c.Set("session_id", session.ID)
+		c.Set("mfa_verified", claims.MFAVerified)
 		c.Next()
 	}
 }
@@ -41,17 +67,28 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 // neung with sha256 hex
 var jwtSecret = []byte("9e21758d56efc1bde0694e859a9f350c305f6901063a8c07b0384ff13f76b051")
 
+// SessionTTL is how long a login token stays valid
+var SessionTTL = 24 * time.Hour
+
+// sessionSeenInterval limits how often a session's last-seen time is written
+const sessionSeenInterval = time.Minute
+
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID    uint `json:"user_id"`
+	SessionID uint `json:"session_id"`
+	// MFAVerified tells whether the login that issued the token passed the second factor
+	MFAVerified bool `json:"mfa_verified"`
 	jwt.StandardClaims
 }
 
-// GenerateToken generates a JWT for a given user ID
-func GenerateToken(userID uint) (string, error) {
+// GenerateToken generates a JWT for a given user ID and login session
+func GenerateToken(userID, sessionID uint, expiresAt time.Time, mfaVerified bool) (string, error) {
 	claims := Claims{
-		UserID: userID,
+		UserID:      userID,
+		SessionID:   sessionID,
+		MFAVerified: mfaVerified,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+			ExpiresAt: expiresAt.Unix(),
 			IssuedAt:  time.Now().Unix(),
 			Issuer:    "gotestbackend",
 		},
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// mfaSecret signs MFA challenge tokens. It is kept apart from jwtSecret so a
+// challenge can never be presented as a login token.
+var mfaSecret = []byte("b7c2e94f0a1d38e65c9b4f7a2d0e81c3f65a9d2e7b40c18f3a6e95d2c7b1f04a")
+
+// MFAChallengeTTL is how long a user has to enter their code after the password step
+var MFAChallengeTTL = 5 * time.Minute
+
+type MFAChallengeClaims struct {
+	UserID uint `json:"user_id"`
+	jwt.StandardClaims
+}
+
+// GenerateMFAChallengeToken issues the token Login returns in place of the JWT for enrolled users
+func GenerateMFAChallengeToken(userID uint) (string, time.Time, error) {
+	expiresAt := time.Now().Add(MFAChallengeTTL)
+	claims := MFAChallengeClaims{
+		UserID: userID,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  time.Now().Unix(),
+			Issuer:    "gotestbackend",
+			Subject:   "mfa-challenge",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(mfaSecret)
+	return signed, expiresAt, err
+}
+
+// ParseMFAChallengeToken parses and validates an MFA challenge token
+func ParseMFAChallengeToken(tokenString string) (*MFAChallengeClaims, error) {
+	claims := &MFAChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return mfaSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.Subject != "mfa-challenge" {
+		return nil, errors.New("invalid MFA challenge token")
+	}
+	return claims, nil
+}
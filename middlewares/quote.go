@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// quoteSecret signs transfer quotes. It is kept apart from jwtSecret so a
+// quote can never be presented as a login token.
+var quoteSecret = []byte("4f1d3c0a8e6b27d95a0c7e31b8f2469d0e5a7c13b9f86d24e07a5c1b3d9f8e62")
+
+// QuoteTTL is how long a transfer quote stays valid
+var QuoteTTL = 5 * time.Minute
+
+// QuoteClaims binds a quote to the transfer it was issued for. The standard
+// claims' Id (jti) lets each quote be redeemed only once.
+type QuoteClaims struct {
+	UserID          uint    `json:"user_id"`
+	ReceiverAccount string  `json:"receiver_account"`
+	Amount          float64 `json:"amount"`
+	Fee             float64 `json:"fee"`
+	Total           float64 `json:"total"`
+	jwt.StandardClaims
+}
+
+// GenerateQuoteToken issues a short-lived token binding a sender, receiver, amount and fee
+func GenerateQuoteToken(userID uint, receiverAccount string, amount, fee float64) (string, time.Time, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(QuoteTTL)
+	claims := QuoteClaims{
+		UserID:          userID,
+		ReceiverAccount: receiverAccount,
+		Amount:          amount,
+		Fee:             fee,
+		Total:           amount + fee,
+		StandardClaims: jwt.StandardClaims{
+			Id:        hex.EncodeToString(raw),
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  time.Now().Unix(),
+			Issuer:    "gotestbackend",
+			Subject:   "transfer-quote",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(quoteSecret)
+	return signed, expiresAt, err
+}
+
+// ParseQuoteToken parses and validates a transfer quote token
+func ParseQuoteToken(tokenString string) (*QuoteClaims, error) {
+	claims := &QuoteClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return quoteSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.Subject != "transfer-quote" || claims.Id == "" {
+		return nil, errors.New("invalid quote token")
+	}
+	return claims, nil
+}
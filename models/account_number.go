@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AccountSequence holds the next sequence value to hand out for an account number prefix
+type AccountSequence struct {
+	Prefix    string `json:"prefix" gorm:"primaryKey;size:9"`
+	NextValue uint64 `json:"next_value"`
+}
+
+// AccountNumberReservation blocks a range of sequence values from automatic allocation
+type AccountNumberReservation struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	Prefix        string    `json:"prefix" gorm:"size:9;index"`
+	FirstSequence uint64    `json:"first_sequence"`
+	LastSequence  uint64    `json:"last_sequence"`
+	Reason        string    `json:"reason"`
+	ReservedBy    uint      `json:"reserved_by"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Account lifecycle states
+const (
+	AccountActive  = "active"
+	AccountFrozen  = "frozen"
+	AccountDormant = "dormant"
+	AccountClosed  = "closed"
+)
+
+// AccountStatusChange records one status transition of a user's account.
+// ChangedBy is 0 when the system made the change.
+type AccountStatusChange struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"index"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	ChangedBy  uint      `json:"changed_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Adjustment directions
+const (
+	AdjustmentCredit = "credit"
+	AdjustmentDebit  = "debit"
+)
+
+// Adjustment states
+const (
+	AdjustmentPending  = "pending"
+	AdjustmentPosted   = "posted"
+	AdjustmentRejected = "rejected"
+)
+
+// BalanceAdjustment is an admin's request to credit or debit an account. It
+// only posts once enough other admins have approved it.
+type BalanceAdjustment struct {
+	ID          uint    `json:"id" gorm:"primaryKey"`
+	UserID      uint    `json:"user_id" gorm:"index"`
+	Direction   string  `json:"direction" gorm:"size:8"`
+	Amount      float64 `json:"amount"`
+	Reason      string  `json:"reason"`
+	EvidenceRef string  `json:"evidence_ref"`
+	RequestedBy uint    `json:"requested_by" gorm:"index"`
+	// RequiredApprovals is fixed when the request is made, from the amount
+	RequiredApprovals int                         `json:"required_approvals"`
+	ApprovalCount     int                         `json:"approval_count"`
+	Status            string                      `json:"status" gorm:"size:16;default:pending;index"`
+	TransactionID     *uint                       `json:"transaction_id"`
+	DecidedAt         *time.Time                  `json:"decided_at"`
+	CreatedAt         time.Time                   `json:"created_at"`
+	UpdatedAt         time.Time                   `json:"updated_at"`
+	Decisions         []BalanceAdjustmentDecision `json:"decisions" gorm:"foreignKey:AdjustmentID"`
+}
+
+// BalanceAdjustmentDecision is one admin approving or rejecting an adjustment
+type BalanceAdjustmentDecision struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	AdjustmentID uint      `json:"adjustment_id" gorm:"index"`
+	AdminID      uint      `json:"admin_id"`
+	Approved     bool      `json:"approved"`
+	Note         string    `json:"note"`
+	CreatedAt    time.Time `json:"created_at"`
+}
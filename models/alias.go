@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Alias types
+const (
+	AliasPhone    = "phone"
+	AliasEmail    = "email"
+	AliasUsername = "username"
+)
+
+// Alias lets payers send to an account by phone, email or username instead of
+// the account number. An alias is only registered for a value the user has
+// verified, and stops resolving if that contact changes.
+type Alias struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index"`
+	Type       string     `json:"alias_type" gorm:"size:16;uniqueIndex:idx_alias"`
+	Value      string     `json:"alias_value" gorm:"size:254;uniqueIndex:idx_alias"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
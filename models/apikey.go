@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// API key scopes
+const (
+	ScopeReadProfile    = "read:profile"
+	ScopeReadTransfers  = "read:transfers"
+	ScopeWriteTransfers = "write:transfers"
+)
+
+// APIKeyScopes lists every scope a key may be granted
+var APIKeyScopes = []string{ScopeReadProfile, ScopeReadTransfers, ScopeWriteTransfers}
+
+// APIKey lets a server act for a user without logging in. Only the hash of the
+// key is stored; Prefix is kept so the owner can tell keys apart.
+type APIKey struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"user_id" gorm:"index"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix" gorm:"size:16"`
+	Hash   string `json:"-" gorm:"size:64;uniqueIndex"`
+	// Scopes is a comma-separated list of APIKeyScopes
+	Scopes string `json:"scopes"`
+	// IPAllowlist is a comma-separated list of IPs or CIDR ranges; empty allows any
+	IPAllowlist string     `json:"ip_allowlist"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
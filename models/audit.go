@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// AuditEntry is one line of the append-only audit log. Hash covers the entry
+// and PrevHash, chaining every entry to the one before it, so editing or
+// removing a line breaks the chain from that point on.
+type AuditEntry struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+	Action     string    `json:"action" gorm:"size:64;index"`
+	ActorID    *uint     `json:"actor_id" gorm:"index"`
+	TargetType string    `json:"target_type" gorm:"size:32"`
+	TargetID   string    `json:"target_id" gorm:"size:255;index"`
+	IP         string    `json:"ip" gorm:"size:64"`
+	RequestID  string    `json:"request_id" gorm:"size:64;index"`
+	// Details is a JSON object, e.g. the field diff of a profile update
+	Details  string `json:"details" gorm:"type:text"`
+	PrevHash string `json:"prev_hash" gorm:"size:64"`
+	Hash     string `json:"hash" gorm:"size:64"`
+}
+
+// AuditChainHead holds the hash of the newest audit entry. Appends lock this
+// single row, so entries are chained one at a time.
+type AuditChainHead struct {
+	ID      uint   `gorm:"primaryKey"`
+	LastID  uint   `json:"last_id"`
+	Hash    string `gorm:"size:64"`
+	Entries int64
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Contact channels
+const (
+	ContactEmail = "email"
+	ContactPhone = "phone"
+)
+
+// ContactVerification is a one-time code sent to prove the user owns an email
+// address or phone number. Only the hash of the code is stored.
+type ContactVerification struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	UserID   uint   `json:"user_id" gorm:"index"`
+	Channel  string `json:"channel" gorm:"size:16"`
+	Value    string `json:"value"`
+	CodeHash string `json:"-"`
+	Attempts int    `json:"attempts" gorm:"default:0"`
+	// VerifiedAt is set once the code is accepted
+	VerifiedAt *time.Time `json:"verified_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
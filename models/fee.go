@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Fee schedule kinds
+const (
+	FeeFlat       = "flat"
+	FeePercentage = "percentage"
+	FeeTiered     = "tiered"
+)
+
+// FeeSchedule prices transfers sent from accounts of one account type.
+// MinFee and MaxFee bound the computed fee when they are non-zero.
+type FeeSchedule struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name"`
+	AccountType string `json:"account_type" gorm:"size:16;index"`
+	Kind        string `json:"kind" gorm:"size:16"`
+	// FlatAmount is used by flat schedules
+	FlatAmount float64 `json:"flat_amount"`
+	// Percentage is used by percentage schedules, e.g. 0.5 for 0.5%
+	Percentage float64 `json:"percentage"`
+	MinFee     float64 `json:"min_fee"`
+	MaxFee     float64 `json:"max_fee"`
+	// FreeTransfersPerMonth transfers each calendar month carry no fee
+	FreeTransfersPerMonth int       `json:"free_transfers_per_month"`
+	Active                bool      `json:"active" gorm:"default:true"`
+	Tiers                 []FeeTier `json:"tiers,omitempty" gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// FeeTier is one band of a tiered schedule. The first tier whose UpToAmount
+// covers the transfer applies; UpToAmount 0 means no upper bound.
+type FeeTier struct {
+	ID            uint    `json:"id" gorm:"primaryKey"`
+	FeeScheduleID uint    `json:"fee_schedule_id" gorm:"index"`
+	UpToAmount    float64 `json:"up_to_amount"`
+	FlatAmount    float64 `json:"flat_amount"`
+	Percentage    float64 `json:"percentage"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Fraud review states
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// FraudReview is a transfer that fraud rules held back. It is only executed
+// once an admin approves it.
+type FraudReview struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	SenderID        uint       `json:"sender_id" gorm:"index"`
+	ReceiverID      uint       `json:"receiver_id" gorm:"index"`
+	ReceiverAccount string     `json:"receiver_account"`
+	Amount          float64    `json:"amount"`
+	Decision        string     `json:"decision" gorm:"size:16"`
+	Rules           string     `json:"rules"`
+	Status          string     `json:"status" gorm:"size:16;default:pending;index"`
+	ReviewedBy      *uint      `json:"reviewed_by"`
+	ReviewedAt      *time.Time `json:"reviewed_at"`
+	ReviewNote      string     `json:"review_note"`
+	TransactionID   *uint      `json:"transaction_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
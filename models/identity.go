@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ExternalIdentity links a user to an account at an OpenID Connect provider
+type ExternalIdentity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index"`
+	Provider    string     `json:"provider" gorm:"size:32;uniqueIndex:idx_external_identity"`
+	Subject     string     `json:"subject" gorm:"size:255;uniqueIndex:idx_external_identity"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// OIDCLoginState is an authorization request waiting for the provider's callback.
+// It is deleted as soon as the callback uses it.
+type OIDCLoginState struct {
+	State        string `gorm:"primaryKey;size:64"`
+	Provider     string `gorm:"size:32"`
+	CodeVerifier string
+	Nonce        string
+	// LinkUserID is set when a logged-in user is linking an identity rather than logging in
+	LinkUserID  *uint
+	DeviceLabel string
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
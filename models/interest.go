@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Day-count conventions for interest accrual
+const (
+	DayCountActual365 = "ACT/365"
+	DayCountActual360 = "ACT/360"
+	DayCount30360     = "30/360"
+)
+
+// InterestProduct is a savings product paying AnnualRate percent on positive credit
+type InterestProduct struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Name       string    `json:"name"`
+	AnnualRate float64   `json:"annual_rate"`
+	DayCount   string    `json:"day_count" gorm:"size:8" enums:"ACT/365,ACT/360,30/360"`
+	Active     bool      `json:"active" gorm:"default:true"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// InterestAccrual is one day of interest earned by one account. Accruals are
+// posted monthly; PostedTransactionID points at the interest transaction, or is
+// 0 when the month's interest rounded down to nothing.
+type InterestAccrual struct {
+	ID                  uint      `json:"id" gorm:"primaryKey"`
+	UserID              uint      `json:"user_id" gorm:"uniqueIndex:idx_accrual_user_date"`
+	AccrualDate         time.Time `json:"accrual_date" gorm:"type:date;uniqueIndex:idx_accrual_user_date"`
+	ProductID           uint      `json:"product_id"`
+	Balance             float64   `json:"balance"`
+	AnnualRate          float64   `json:"annual_rate"`
+	Amount              float64   `json:"amount"`
+	PostedTransactionID *uint     `json:"posted_transaction_id" gorm:"index"`
+	CreatedAt           time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Login throttle kinds
+const (
+	ThrottleUsername = "username"
+	ThrottleIP       = "ip"
+)
+
+// LoginThrottle counts recent failed logins for one subject: a username or a client IP
+type LoginThrottle struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Kind          string     `json:"kind" gorm:"size:16;uniqueIndex:idx_login_throttle_key"`
+	Subject       string     `json:"subject" gorm:"size:255;uniqueIndex:idx_login_throttle_key"`
+	Failures      int        `json:"failures" gorm:"default:0"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// MFAEnrollment is a user's TOTP authenticator. It only protects login once ConfirmedAt is set.
+type MFAEnrollment struct {
+	UserID      uint       `json:"user_id" gorm:"primaryKey"`
+	Secret      string     `json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	// LastUsedStep stops a code from being replayed within its time window
+	LastUsedStep int64     `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// MFARecoveryCode is a one-time code that stands in for the authenticator. Only its hash is stored.
+type MFARecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	Hash      string     `json:"-" gorm:"size:64;uniqueIndex"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
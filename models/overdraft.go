@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// OverdraftLimitChange records an admin changing a user's overdraft limit
+type OverdraftLimitChange struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	OldLimit  float64   `json:"old_limit"`
+	NewLimit  float64   `json:"new_limit"`
+	Reason    string    `json:"reason"`
+	ChangedBy uint      `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
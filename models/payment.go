@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Payment kinds
+const (
+	PaymentDeposit    = "deposit"
+	PaymentWithdrawal = "withdrawal"
+)
+
+// Payment states
+const (
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+)
+
+// Payment is money moving between an account and the outside world through a
+// payment rail. It posts to the ledger only once the rail reports success.
+type Payment struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"user_id" gorm:"index"`
+	Kind   string `json:"kind" gorm:"size:16"`
+	Rail   string `json:"rail" gorm:"size:32"`
+	// Reference is ours and is sent to the rail; callbacks name the payment by it
+	Reference  string `json:"reference" gorm:"size:64;uniqueIndex"`
+	ExternalID string `json:"external_id" gorm:"size:128;index"`
+	// ExternalAccount is where a deposit comes from or a withdrawal goes to, e.g. a bank account or card
+	ExternalAccount string     `json:"external_account"`
+	Amount          float64    `json:"amount"`
+	Status          string     `json:"status" gorm:"size:16;default:pending;index"`
+	FailureReason   string     `json:"failure_reason,omitempty"`
+	TransactionID   *uint      `json:"transaction_id"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// TransactionPIN is the step-up PIN a user enters to authorise large or
+// challenged transfers. It is kept apart from User so the hash never ends up
+// in a user response.
+type TransactionPIN struct {
+	UserID         uint       `json:"user_id" gorm:"primaryKey"`
+	Hash           string     `json:"-"`
+	FailedAttempts int        `json:"failed_attempts" gorm:"default:0"`
+	LockedUntil    *time.Time `json:"locked_until"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
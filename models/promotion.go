@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Promotion kinds
+const (
+	// PromotionSignup campaigns are granted automatically when an account is opened
+	PromotionSignup = "signup"
+	// PromotionManual campaigns are granted by an admin, one user at a time
+	PromotionManual = "manual"
+)
+
+// Promotion is a campaign paying a fixed amount to each eligible user once,
+// from the house promotions account, until its budget runs out
+type Promotion struct {
+	ID        uint    `json:"id" gorm:"primaryKey"`
+	Name      string  `json:"name" gorm:"size:100"`
+	Kind      string  `json:"kind" gorm:"size:16;index"`
+	Amount    float64 `json:"amount"`
+	BudgetCap float64 `json:"budget_cap"`
+	// Spent and GrantCount are kept up to date as grants are made
+	Spent      float64 `json:"spent"`
+	GrantCount int     `json:"grant_count"`
+	// StartsAt and EndsAt bound when grants are made; nil means open-ended.
+	// Sign-up campaigns also only reach accounts opened from StartsAt.
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	// AccountType limits the campaign to personal or business accounts; empty means both
+	AccountType string    `json:"account_type" gorm:"size:16"`
+	Active      bool      `json:"active" gorm:"default:true"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PromotionGrant is one payment of a promotion to a user
+type PromotionGrant struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	PromotionID   uint      `json:"promotion_id" gorm:"uniqueIndex:idx_promotion_user"`
+	UserID        uint      `json:"user_id" gorm:"uniqueIndex:idx_promotion_user;index"`
+	Amount        float64   `json:"amount"`
+	TransactionID uint      `json:"transaction_id"`
+	GrantedBy     *uint     `json:"granted_by"`
+	CreatedAt     time.Time `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RedeemedQuote records a transfer quote that has been used, so it cannot pay twice
+type RedeemedQuote struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	QuoteID   string    `json:"quote_id" gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session is one login. Its ID travels in the JWT so a single device can be signed out.
+type Session struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index"`
+	DeviceLabel string     `json:"device_label"`
+	IP          string     `json:"ip" gorm:"size:64"`
+	UserAgent   string     `json:"user_agent"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
@@ -4,13 +4,52 @@ import (
 	"time"
 )
 
+// Transaction kinds. A transfer may have child lines, such as its fee, pointing at it through ParentID.
+const (
+	TransactionTransfer = "transfer"
+	TransactionFee      = "fee"
+	TransactionInterest = "interest"
+	// TransactionAdjustment lines are written by reconciliation to explain a balance difference
+	TransactionAdjustment = "adjustment"
+	// TransactionManualAdjustment lines post an approved models.BalanceAdjustment
+	TransactionManualAdjustment = "manual_adjustment"
+	// TransactionDeposit and TransactionWithdrawal lines post a succeeded models.Payment against the settlement account
+	TransactionDeposit    = "deposit"
+	TransactionWithdrawal = "withdrawal"
+	// TransactionPromotion lines pay a models.PromotionGrant from the promotions account
+	TransactionPromotion = "promotion"
+)
+
 type Transaction struct {
-	ID                uint      `json:"id" gorm:"primaryKey"`
-	SenderID          uint      `json:"sender_id"`
-	SenderRemaining   float64   `json:"ender_remaining "`
-	ReceiverID        uint      `json:"receiver_id"`
-	ReceiverRemaining float64   `json:"receiver_remaining "`
-	Amount            float64   `json:"amount"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID                uint    `json:"id" gorm:"primaryKey"`
+	SenderID          uint    `json:"sender_id"`
+	SenderRemaining   float64 `json:"ender_remaining "`
+	ReceiverID        uint    `json:"receiver_id"`
+	ReceiverRemaining float64 `json:"receiver_remaining "`
+	Amount            float64 `json:"amount"`
+	// Fee charged on top of Amount; it is posted as its own fee line
+	Fee       float64   `json:"fee"`
+	Kind      string    `json:"kind" gorm:"size:32;default:transfer;index"`
+	ParentID  *uint     `json:"parent_id,omitempty" gorm:"index"`
+	Memo      string    `json:"memo,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Hash covers the line's contents and both previous hashes. Each side links
+	// to the account's previous line, so every account has its own chain.
+	Hash             string `json:"hash" gorm:"size:64;index"`
+	SenderPrevHash   string `json:"sender_prev_hash" gorm:"size:64"`
+	ReceiverPrevHash string `json:"receiver_prev_hash" gorm:"size:64"`
+	// Sender and Receiver only exist to create the foreign keys to users
+	Sender   *User `json:"-" gorm:"foreignKey:SenderID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	Receiver *User `json:"-" gorm:"foreignKey:ReceiverID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+}
+
+// LedgerChainHead is the newest hash of one account's transaction chain and
+// how many transactions it covers. It is updated in the posting transaction,
+// so removing the newest lines, or rehashing the chain after an edit, no
+// longer matches it.
+type LedgerChainHead struct {
+	UserID       uint   `gorm:"primaryKey"`
+	Hash         string `gorm:"size:64"`
+	Transactions int64
 }
@@ -1,13 +1,87 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Roles a user can hold
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	// RoleSystem marks house accounts owned by the bank itself; they cannot log in
+	RoleSystem = "system"
+)
+
+// Usernames of the house accounts created by migration
+const (
+	SystemAccountFees     = "house_fees"
+	SystemAccountInterest = "house_interest"
+	SystemAccountSuspense = "house_suspense"
+	// SystemAccountAdjustments funds and absorbs approved balance adjustments
+	SystemAccountAdjustments = "house_adjustments"
+	// SystemAccountSettlement mirrors money held at payment rails for deposits and withdrawals
+	SystemAccountSettlement = "house_settlement"
+	// SystemAccountPromotions funds promotion grants such as the sign-up bonus
+	SystemAccountPromotions = "house_promotions"
+)
+
+// LegacyOpeningBalance is the credit customer accounts used to be opened with,
+// before the sign-up bonus became a promotion posted through the ledger
+const LegacyOpeningBalance = 1000.0
+
+// Account types
+const (
+	AccountTypePersonal = "personal"
+	AccountTypeBusiness = "business"
+)
+
 // @description User represents the entity of a user with basic information like username, personal details, account number, and credit balance.
 type User struct {
 	ID        uint   `json:"id" gorm:"primary_key"`
 	Username  string `json:"username"`
-	Password  string `json:"password"`
+	Password  string `json:"-"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	// @description The account number associated with the user.
 	AccountNumber string  `json:"account_number"`
 	Credit        float64 `json:"credit"`
+	// @description Set by migration for account numbers issued before check digits were introduced.
+	LegacyAccountNumber bool   `json:"legacy_account_number" gorm:"default:false"`
+	Role                string `json:"role" gorm:"size:16;default:user"`
+	// @description personal or business; selects the fee schedule.
+	AccountType string `json:"account_type" gorm:"size:16;default:personal"`
+	// @description Savings product the account earns interest under, if any.
+	InterestProductID *uint `json:"interest_product_id"`
+	// @description Lifecycle state: active, frozen, dormant or closed.
+	Status          string     `json:"status" gorm:"size:16;default:active;index"`
+	StatusReason    string     `json:"status_reason"`
+	StatusChangedAt *time.Time `json:"status_changed_at"`
+	LastActivityAt  *time.Time `json:"last_activity_at"`
+	// @description How far below zero Credit may go. Set by admins.
+	OverdraftLimit float64 `json:"overdraft_limit" gorm:"default:0"`
+	// @description When Credit last went below zero; cleared once it is back at zero or above.
+	OverdrawnSince *time.Time `json:"overdrawn_since"`
+	// @description Set by the nightly job when the account stays overdrawn beyond the grace period.
+	OverdraftFlaggedAt *time.Time `json:"overdraft_flagged_at"`
+	// @description Credit set aside for pending withdrawals; it cannot be spent until they settle.
+	HeldAmount float64 `json:"held_amount" gorm:"default:0"`
+	// OpeningBalance is the credit the account was opened with, before any
+	// transaction. Only accounts opened before sign-up promotions have one.
+	OpeningBalance float64 `json:"-"`
+	// @description When the account was opened; empty for accounts opened before it was recorded.
+	OpenedAt *time.Time `json:"opened_at"`
+	// @description Unique; changed through /user/me, which requires verifying it again.
+	Email           *string    `json:"email" gorm:"size:254;uniqueIndex"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// @description Unique, in E.164 format such as +66812345678.
+	Phone           *string    `json:"phone" gorm:"size:20;uniqueIndex"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
+	// @description Tokens issued before this are no longer accepted.
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	// @description Login refuses to issue a session until the password is reset, e.g. for the bootstrap admin.
+	PasswordResetRequired bool `json:"password_reset_required" gorm:"default:false"`
+	// @description Set when the user is soft-deleted; deleted users are hidden from lookups and login.
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index" swaggertype:"string" format:"date-time"`
 }
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"gotestbackend/models"
+	"gotestbackend/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AccountNumberPrefix starts every newly allocated account number. Set from
+// ACCOUNT_NUMBER_PREFIX at startup.
+var AccountNumberPrefix = "10"
+
+var (
+	// ErrAccountNumbersExhausted is returned when every sequence value for the prefix is used or reserved
+	ErrAccountNumbersExhausted = errors.New("no account numbers left for prefix")
+	// ErrInvalidReservation is returned for an empty, out-of-range or already issued reservation
+	ErrInvalidReservation = errors.New("invalid account number reservation")
+	// ErrReservationOverlap is returned when a reservation overlaps an existing one
+	ErrReservationOverlap = errors.New("reservation overlaps an existing range")
+)
+
+// sequenceWidth is the number of sequence digits left between the prefix and the check digit
+func sequenceWidth(prefix string) int {
+	return utils.AccountNumberLength - 1 - len(prefix)
+}
+
+// maxSequence is the largest sequence value that fits after the prefix
+func maxSequence(prefix string) uint64 {
+	return uint64(math.Pow10(sequenceWidth(prefix))) - 1
+}
+
+// FormatAccountNumber joins prefix, zero-padded sequence and check digit
+func FormatAccountNumber(prefix string, sequence uint64) string {
+	base := fmt.Sprintf("%s%0*d", prefix, sequenceWidth(prefix), sequence)
+	return base + string(utils.LuhnCheckDigit(base))
+}
+
+// AllocateAccountNumber hands out the next free account number for AccountNumberPrefix.
+// It must run inside a database transaction so the sequence row lock is held until commit.
+func AllocateAccountNumber(tx *gorm.DB) (string, error) {
+	prefix := AccountNumberPrefix
+	seq := models.AccountSequence{Prefix: prefix, NextValue: 1}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&seq, "prefix = ?", prefix).Error; err != nil {
+		return "", err
+	}
+	var reservations []models.AccountNumberReservation
+	if err := tx.Where("prefix = ? AND last_sequence >= ?", prefix, seq.NextValue).Order("first_sequence").Find(&reservations).Error; err != nil {
+		return "", err
+	}
+	next := seq.NextValue
+	for {
+		for _, r := range reservations {
+			if next >= r.FirstSequence && next <= r.LastSequence {
+				next = r.LastSequence + 1
+			}
+		}
+		if next > maxSequence(prefix) {
+			return "", ErrAccountNumbersExhausted
+		}
+		number := FormatAccountNumber(prefix, next)
+		// Skip numbers that were hand-assigned before allocation existed,
+		// including those of soft-deleted users who may be restored
+		var taken int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("account_number = ?", number).Count(&taken).Error; err != nil {
+			return "", err
+		}
+		next++
+		if taken == 0 {
+			seq.NextValue = next
+			if err := tx.Save(&seq).Error; err != nil {
+				return "", err
+			}
+			return number, nil
+		}
+	}
+}
+
+// ReserveAccountNumbers blocks a range of sequence values for a prefix from automatic allocation
+func ReserveAccountNumbers(db *gorm.DB, reservation *models.AccountNumberReservation) error {
+	if reservation.Prefix == "" {
+		reservation.Prefix = AccountNumberPrefix
+	}
+	prefix := reservation.Prefix
+	if len(prefix) >= utils.AccountNumberLength-1 || strings.Trim(prefix, "0123456789") != "" {
+		return fmt.Errorf("%w: prefix must be digits", ErrInvalidReservation)
+	}
+	if reservation.FirstSequence == 0 || reservation.FirstSequence > reservation.LastSequence || reservation.LastSequence > maxSequence(prefix) {
+		return fmt.Errorf("%w: sequence range must be within 1-%d", ErrInvalidReservation, maxSequence(prefix))
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var seq models.AccountSequence
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&seq, "prefix = ?", prefix).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && reservation.FirstSequence < seq.NextValue {
+			return fmt.Errorf("%w: numbers below %d are already issued", ErrInvalidReservation, seq.NextValue)
+		}
+		var overlapping int64
+		if err := tx.Model(&models.AccountNumberReservation{}).
+			Where("prefix = ? AND first_sequence <= ? AND last_sequence >= ?", prefix, reservation.LastSequence, reservation.FirstSequence).
+			Count(&overlapping).Error; err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return ErrReservationOverlap
+		}
+		return tx.Create(reservation).Error
+	})
+}
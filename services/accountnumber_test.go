@@ -0,0 +1,37 @@
+package services_test
+
+import (
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"gotestbackend/utils"
+
+	"gorm.io/gorm"
+)
+
+func TestAllocateAccountNumber(t *testing.T) {
+	db := testutil.NewDB(t)
+	// Sequence 2 was hand-assigned before allocation existed and 3-4 are reserved
+	testutil.CreateUser(t, db, "legacy", "100000002", 0)
+	if err := services.ReserveAccountNumbers(db, &models.AccountNumberReservation{Prefix: "10", FirstSequence: 3, LastSequence: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"1000000016", "1000000057", "1000000065"}
+	for _, number := range want {
+		var got string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			got, err = services.AllocateAccountNumber(tx)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != number || !utils.HasValidCheckDigit(got) {
+			t.Errorf("AllocateAccountNumber() = %s, want %s", got, number)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DormancyDays is how long an active account may go without activity before it
+// is marked dormant. Set from DORMANCY_DAYS at startup.
+var DormancyDays = 365
+
+// ErrInvalidStatusTransition is returned for a status change the lifecycle does not allow
+var ErrInvalidStatusTransition = errors.New("status transition not allowed")
+
+// ErrAccountNotSettled is returned when closing an account that still has credit or held funds
+var ErrAccountNotSettled = errors.New("account still has credit or held funds")
+
+// statusTransitions lists the states each state may move to. Closed is final.
+var statusTransitions = map[string][]string{
+	models.AccountActive:  {models.AccountFrozen, models.AccountDormant, models.AccountClosed},
+	models.AccountFrozen:  {models.AccountActive, models.AccountClosed},
+	models.AccountDormant: {models.AccountActive, models.AccountFrozen, models.AccountClosed},
+	models.AccountClosed:  {},
+}
+
+// IsValidAccountStatus reports whether status is a known lifecycle state
+func IsValidAccountStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether an account may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeAccountStatus moves a user's account to a new status and records the change.
+// changedBy is the acting admin, or 0 for the system.
+func ChangeAccountStatus(db *gorm.DB, userID uint, to, reason string, changedBy uint) (models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return lookupError(err)
+		}
+		from := user.Status
+		if from == "" {
+			from = models.AccountActive
+		}
+		if !CanTransition(from, to) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, from, to)
+		}
+		// Closed is final, so no money may be left behind in the account
+		if to == models.AccountClosed && (user.Credit != 0 || user.HeldAmount != 0) {
+			return ErrAccountNotSettled
+		}
+		now := time.Now()
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"status":            to,
+			"status_reason":     reason,
+			"status_changed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AccountStatusChange{
+			UserID:     user.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			Reason:     reason,
+			ChangedBy:  changedBy,
+		}).Error
+	})
+	return user, err
+}
+
+// MarkDormantAccounts moves active accounts with no activity for DormancyDays to dormant
+func MarkDormantAccounts(db *gorm.DB, now time.Time) (int, error) {
+	cutoff := now.AddDate(0, 0, -DormancyDays)
+	var users []models.User
+	if err := db.Where("status = ? AND last_activity_at < ? AND role <> ?", models.AccountActive, cutoff, models.RoleSystem).Find(&users).Error; err != nil {
+		return 0, err
+	}
+	marked := 0
+	for _, user := range users {
+		reason := fmt.Sprintf("No activity for %d days", DormancyDays)
+		if _, err := ChangeAccountStatus(db, user.ID, models.AccountDormant, reason, 0); err != nil {
+			return marked, err
+		}
+		marked++
+	}
+	return marked, nil
+}
+
+// StartDormancyJob runs MarkDormantAccounts every interval until the process exits
+func StartDormancyJob(db *gorm.DB, interval time.Duration) {
+	runEvery("Dormancy", interval, func(now time.Time) (int, error) {
+		return MarkDormantAccounts(db, now)
+	})
+}
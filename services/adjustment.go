@@ -0,0 +1,176 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdjustmentDualApprovalAmount is the amount from which an adjustment needs two
+// approvers instead of one. Set from ADJUSTMENT_DUAL_APPROVAL_AMOUNT at startup.
+var AdjustmentDualApprovalAmount = 10000.0
+
+var (
+	// ErrInvalidAdjustment is returned when an adjustment request is missing something or has a bad amount
+	ErrInvalidAdjustment = errors.New("invalid adjustment")
+	// ErrAdjustmentNotPending is returned when an adjustment was already posted or rejected
+	ErrAdjustmentNotPending = errors.New("adjustment is not pending")
+	// ErrSelfApproval is returned when the admin who asked for an adjustment tries to approve it
+	ErrSelfApproval = errors.New("adjustments must be approved by a different admin")
+	// ErrAlreadyDecided is returned when an admin approves the same adjustment twice
+	ErrAlreadyDecided = errors.New("admin already approved this adjustment")
+	// ErrAdjustmentInsufficientCredit is returned when a debit would take more than the account has available
+	ErrAdjustmentInsufficientCredit = errors.New("insufficient credit for debit")
+)
+
+// RequestAdjustment records an admin's request to credit or debit a customer account
+func RequestAdjustment(db *gorm.DB, requestedBy, userID uint, direction string, amount float64, reason, evidenceRef string) (models.BalanceAdjustment, error) {
+	reason, evidenceRef = strings.TrimSpace(reason), strings.TrimSpace(evidenceRef)
+	switch {
+	case direction != models.AdjustmentCredit && direction != models.AdjustmentDebit:
+		return models.BalanceAdjustment{}, fmt.Errorf("%w: direction must be credit or debit", ErrInvalidAdjustment)
+	case amount <= 0 || roundMoney(amount) != amount:
+		return models.BalanceAdjustment{}, fmt.Errorf("%w: amount must be positive with at most two decimals", ErrInvalidAdjustment)
+	case reason == "":
+		return models.BalanceAdjustment{}, fmt.Errorf("%w: reason is required", ErrInvalidAdjustment)
+	case evidenceRef == "":
+		return models.BalanceAdjustment{}, fmt.Errorf("%w: evidence_ref is required", ErrInvalidAdjustment)
+	}
+	user, err := GetUserByID(db, userID)
+	if err != nil {
+		return models.BalanceAdjustment{}, err
+	}
+	if user.Role == models.RoleSystem {
+		return models.BalanceAdjustment{}, fmt.Errorf("%w: house accounts cannot be adjusted", ErrInvalidAdjustment)
+	}
+	adjustment := models.BalanceAdjustment{
+		UserID:            userID,
+		Direction:         direction,
+		Amount:            amount,
+		Reason:            reason,
+		EvidenceRef:       evidenceRef,
+		RequestedBy:       requestedBy,
+		RequiredApprovals: 1,
+		Status:            models.AdjustmentPending,
+		Decisions:         []models.BalanceAdjustmentDecision{},
+	}
+	if amount >= AdjustmentDualApprovalAmount {
+		adjustment.RequiredApprovals = 2
+	}
+	err = db.Create(&adjustment).Error
+	return adjustment, err
+}
+
+// lockPendingAdjustment re-reads an adjustment under lock and checks it can still be decided
+func lockPendingAdjustment(tx *gorm.DB, id uint) (models.BalanceAdjustment, error) {
+	var adjustment models.BalanceAdjustment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&adjustment, id).Error; err != nil {
+		return adjustment, err
+	}
+	if adjustment.Status != models.AdjustmentPending {
+		return adjustment, ErrAdjustmentNotPending
+	}
+	return adjustment, nil
+}
+
+// ApproveAdjustment records an admin's approval. The last approval needed
+// posts the adjustment against the house adjustments account, in the same
+// database transaction, so a failed posting leaves the approval unrecorded.
+func ApproveAdjustment(db *gorm.DB, id, adminID uint, note string) (models.BalanceAdjustment, error) {
+	var adjustment models.BalanceAdjustment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if adjustment, err = lockPendingAdjustment(tx, id); err != nil {
+			return err
+		}
+		if adjustment.RequestedBy == adminID {
+			return ErrSelfApproval
+		}
+		// The row lock above keeps two approvals by the same admin from racing
+		var decided int64
+		if err := tx.Model(&models.BalanceAdjustmentDecision{}).Where("adjustment_id = ? AND admin_id = ? AND approved = ?", id, adminID, true).Count(&decided).Error; err != nil {
+			return err
+		}
+		if decided > 0 {
+			return ErrAlreadyDecided
+		}
+		if err := tx.Create(&models.BalanceAdjustmentDecision{AdjustmentID: id, AdminID: adminID, Approved: true, Note: note}).Error; err != nil {
+			return err
+		}
+		adjustment.ApprovalCount++
+		if adjustment.ApprovalCount < adjustment.RequiredApprovals {
+			return tx.Model(&adjustment).Update("approval_count", adjustment.ApprovalCount).Error
+		}
+		txn, err := postAdjustmentRequest(tx, adjustment)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		adjustment.Status, adjustment.TransactionID, adjustment.DecidedAt = models.AdjustmentPosted, &txn.ID, &now
+		return tx.Model(&adjustment).Updates(map[string]interface{}{
+			"approval_count": adjustment.ApprovalCount,
+			"status":         adjustment.Status,
+			"transaction_id": txn.ID,
+			"decided_at":     now,
+		}).Error
+	})
+	if err != nil {
+		return adjustment, err
+	}
+	return GetAdjustment(db, id)
+}
+
+// postAdjustmentRequest moves the adjustment between the user and the house adjustments account
+func postAdjustmentRequest(tx *gorm.DB, adjustment models.BalanceAdjustment) (models.Transaction, error) {
+	house, err := SystemAccount(tx, models.SystemAccountAdjustments)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	user := models.User{ID: adjustment.UserID}
+	if err := LockUsers(tx, &user, &house); err != nil {
+		return models.Transaction{}, err
+	}
+	txn := models.Transaction{
+		Kind: models.TransactionManualAdjustment,
+		Memo: fmt.Sprintf("Adjustment #%d: %s", adjustment.ID, adjustment.Reason),
+	}
+	if adjustment.Direction == models.AdjustmentCredit {
+		return PostLedger(tx, &house, &user, adjustment.Amount, txn)
+	}
+	if AvailableBalance(user) < adjustment.Amount {
+		return models.Transaction{}, ErrAdjustmentInsufficientCredit
+	}
+	return PostLedger(tx, &user, &house, adjustment.Amount, txn)
+}
+
+// RejectAdjustment closes a pending adjustment without posting it. Any admin
+// may reject, including the one who asked for it.
+func RejectAdjustment(db *gorm.DB, id, adminID uint, note string) (models.BalanceAdjustment, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		adjustment, err := lockPendingAdjustment(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&models.BalanceAdjustmentDecision{AdjustmentID: id, AdminID: adminID, Approved: false, Note: note}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&adjustment).Updates(map[string]interface{}{"status": models.AdjustmentRejected, "decided_at": time.Now()}).Error
+	})
+	if err != nil {
+		return models.BalanceAdjustment{}, err
+	}
+	return GetAdjustment(db, id)
+}
+
+// GetAdjustment loads an adjustment with every decision made on it
+func GetAdjustment(db *gorm.DB, id uint) (models.BalanceAdjustment, error) {
+	var adjustment models.BalanceAdjustment
+	err := db.Preload("Decisions", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&adjustment, id).Error
+	return adjustment, err
+}
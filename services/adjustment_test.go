@@ -0,0 +1,61 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+)
+
+func TestApproveAdjustmentMakerChecker(t *testing.T) {
+	type approval struct {
+		by   int // index into the admins: 0 made the request
+		want error
+	}
+	tests := []struct {
+		name      string
+		amount    float64
+		approvals []approval
+		status    string
+		credit    float64
+	}{
+		{"self-approval refused", 100, []approval{{0, services.ErrSelfApproval}}, models.AdjustmentPending, 0},
+		{"checker posts it", 100, []approval{{0, services.ErrSelfApproval}, {1, nil}}, models.AdjustmentPosted, 100},
+		{"large amount waits for a second checker", services.AdjustmentDualApprovalAmount, []approval{{1, nil}}, models.AdjustmentPending, 0},
+		{"same checker twice", services.AdjustmentDualApprovalAmount, []approval{{1, nil}, {1, services.ErrAlreadyDecided}}, models.AdjustmentPending, 0},
+		{"maker cannot be the second checker", services.AdjustmentDualApprovalAmount, []approval{{1, nil}, {0, services.ErrSelfApproval}}, models.AdjustmentPending, 0},
+		{"two checkers post it", services.AdjustmentDualApprovalAmount, []approval{{1, nil}, {2, nil}, {0, services.ErrAdjustmentNotPending}}, models.AdjustmentPosted, services.AdjustmentDualApprovalAmount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			customer := testutil.CreateUser(t, db, "customer", "123456789", 0)
+			admins := []models.User{
+				testutil.CreateUser(t, db, "maker", "234567891", 0),
+				testutil.CreateUser(t, db, "checker", "345678912", 0),
+				testutil.CreateUser(t, db, "checker2", "456789123", 0),
+			}
+			adjustment, err := services.RequestAdjustment(db, admins[0].ID, customer.ID, models.AdjustmentCredit, tt.amount, "Refund", "TICKET-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, a := range tt.approvals {
+				if _, err := services.ApproveAdjustment(db, adjustment.ID, admins[a.by].ID, ""); !errors.Is(err, a.want) {
+					t.Errorf("approval %d by %s: err = %v, want %v", i+1, admins[a.by].Username, err, a.want)
+				}
+			}
+			got, err := services.GetAdjustment(db, adjustment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.status {
+				t.Errorf("status = %s, want %s", got.Status, tt.status)
+			}
+			if credit := testutil.Credit(t, db, customer.ID); credit != tt.credit {
+				t.Errorf("credit = %v, want %v", credit, tt.credit)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidAlias is returned for an unknown alias type or a malformed value
+	ErrInvalidAlias = errors.New("invalid alias")
+	// ErrAliasUnverified is returned when the value is not the user's own verified contact or username
+	ErrAliasUnverified = errors.New("alias must be your own verified email, verified phone or username")
+	// ErrAliasTaken is returned when the alias is already registered
+	ErrAliasTaken = errors.New("alias already registered")
+	// ErrAliasNotFound is returned when no live alias matches
+	ErrAliasNotFound = errors.New("alias not found")
+)
+
+// normaliseAlias validates an alias and returns its stored form
+func normaliseAlias(aliasType, value string) (string, error) {
+	switch aliasType {
+	case models.AliasEmail:
+		return NormaliseContact(models.ContactEmail, value)
+	case models.AliasPhone:
+		return NormaliseContact(models.ContactPhone, value)
+	case models.AliasUsername:
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("%w: username is empty", ErrInvalidAlias)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: alias_type must be phone, email or username", ErrInvalidAlias)
+}
+
+// aliasBacked reports whether the user still owns the alias value: their
+// username, or their email or phone while it is verified
+func aliasBacked(user models.User, aliasType, value string) bool {
+	switch aliasType {
+	case models.AliasUsername:
+		return user.Username == value
+	case models.AliasEmail:
+		current, verifiedAt := contactOf(user, models.ContactEmail)
+		return verifiedAt != nil && current == value
+	case models.AliasPhone:
+		current, verifiedAt := contactOf(user, models.ContactPhone)
+		return verifiedAt != nil && current == value
+	}
+	return false
+}
+
+// RegisterAlias maps one of the user's verified contacts, or their username, to their account
+func RegisterAlias(db *gorm.DB, user models.User, aliasType, value string) (models.Alias, error) {
+	normalised, err := normaliseAlias(aliasType, value)
+	if err != nil {
+		return models.Alias{}, err
+	}
+	if !aliasBacked(user, aliasType, normalised) {
+		return models.Alias{}, ErrAliasUnverified
+	}
+	var existing models.Alias
+	err = db.Where("type = ? AND value = ?", aliasType, normalised).First(&existing).Error
+	if err == nil {
+		if existing.UserID == user.ID {
+			return existing, nil
+		}
+		// A stale alias left behind by a previous owner of the contact is released
+		owner, lookupErr := GetUserByID(db, existing.UserID)
+		if lookupErr == nil && aliasBacked(owner, existing.Type, existing.Value) {
+			return models.Alias{}, ErrAliasTaken
+		}
+		if err := db.Delete(&existing).Error; err != nil {
+			return models.Alias{}, err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Alias{}, err
+	}
+	now := time.Now()
+	alias := models.Alias{
+		UserID:     user.ID,
+		Type:       aliasType,
+		Value:      normalised,
+		VerifiedAt: &now,
+	}
+	if err := db.Create(&alias).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return models.Alias{}, ErrAliasTaken
+		}
+		return models.Alias{}, err
+	}
+	return alias, nil
+}
+
+// ResolveAlias returns the current account number of the alias's owner. Aliases
+// whose contact has since changed or lost its verification do not resolve.
+func ResolveAlias(db *gorm.DB, aliasType, value string) (string, error) {
+	normalised, err := normaliseAlias(aliasType, value)
+	if err != nil {
+		return "", err
+	}
+	var alias models.Alias
+	if err := db.Where("type = ? AND value = ?", aliasType, normalised).First(&alias).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrAliasNotFound
+		}
+		return "", err
+	}
+	owner, err := GetUserByID(db, alias.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return "", ErrAliasNotFound
+		}
+		return "", err
+	}
+	if !aliasBacked(owner, alias.Type, alias.Value) {
+		return "", ErrAliasNotFound
+	}
+	return owner.AccountNumber, nil
+}
+
+// DeleteAlias removes one of the user's aliases
+func DeleteAlias(db *gorm.DB, userID, aliasID uint) error {
+	result := db.Where("id = ? AND user_id = ?", aliasID, userID).Delete(&models.Alias{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAliasNotFound
+	}
+	return nil
+}
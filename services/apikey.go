@@ -0,0 +1,139 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix marks our keys so they are easy to spot in logs and secret scanners
+const apiKeyPrefix = "gtb_"
+
+var (
+	// ErrInvalidAPIKeyRequest is returned when a key is asked for with bad scopes, allowlist or expiry
+	ErrInvalidAPIKeyRequest = errors.New("invalid API key request")
+	// ErrAPIKeyRejected is returned when a presented key is unknown, revoked, expired or used from a disallowed IP
+	ErrAPIKeyRejected = errors.New("API key rejected")
+	// ErrAPIKeyNotFound is returned when the user has no such key
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// validScope reports whether scope is one of models.APIKeyScopes
+func validScope(scope string) bool {
+	for _, known := range models.APIKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKey issues a key for the user and returns it with the raw key,
+// which is only ever shown here
+func CreateAPIKey(db *gorm.DB, userID uint, name string, scopes, allowlist []string, expiresAt *time.Time) (models.APIKey, string, error) {
+	if len(scopes) == 0 {
+		return models.APIKey{}, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return models.APIKey{}, "", fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyRequest, scope)
+		}
+	}
+	for _, entry := range allowlist {
+		if net.ParseIP(entry) == nil {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return models.APIKey{}, "", fmt.Errorf("%w: %q is not an IP or CIDR range", ErrInvalidAPIKeyRequest, entry)
+			}
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return models.APIKey{}, "", fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyRequest)
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return models.APIKey{}, "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(raw)
+	record := models.APIKey{
+		UserID:      userID,
+		Name:        name,
+		Prefix:      key[:len(apiKeyPrefix)+8],
+		Hash:        hashToken(key),
+		Scopes:      strings.Join(scopes, ","),
+		IPAllowlist: strings.Join(allowlist, ","),
+		ExpiresAt:   expiresAt,
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return models.APIKey{}, "", err
+	}
+	return record, key, nil
+}
+
+// ipAllowed reports whether ip matches the comma-separated allowlist
+func ipAllowed(allowlist, ip string) bool {
+	if allowlist == "" {
+		return true
+	}
+	client := net.ParseIP(ip)
+	if client == nil {
+		return false
+	}
+	for _, entry := range strings.Split(allowlist, ",") {
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(client) {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(client) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthenticateAPIKey looks up a presented key and checks it may be used from ip
+func AuthenticateAPIKey(db *gorm.DB, key, ip string, now time.Time) (models.APIKey, error) {
+	var record models.APIKey
+	if err := db.Where("hash = ? AND revoked_at IS NULL", hashToken(key)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return record, ErrAPIKeyRejected
+		}
+		return record, err
+	}
+	if record.ExpiresAt != nil && !now.Before(*record.ExpiresAt) {
+		return record, ErrAPIKeyRejected
+	}
+	if !ipAllowed(record.IPAllowlist, ip) {
+		return record, ErrAPIKeyRejected
+	}
+	db.Model(&record).Update("last_used_at", now)
+	return record, nil
+}
+
+// APIKeyScopeList splits a key's stored scopes
+func APIKeyScopeList(key models.APIKey) []string {
+	if key.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(key.Scopes, ",")
+}
+
+// RevokeAPIKey stops one of the user's keys from working
+func RevokeAPIKey(db *gorm.DB, userID, keyID uint) error {
+	result := db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Audit actions
+const (
+	AuditLoginSuccess  = "login.success"
+	AuditLoginFailure  = "login.failure"
+	AuditProfileUpdate = "user.update"
+	AuditUserDelete    = "user.delete"
+	AuditTransfer      = "transfer"
+	AuditPayment       = "payment"
+	AuditAdminRequest  = "admin.request"
+)
+
+// auditHeadID is the primary key of the single chain head row
+const auditHeadID = 1
+
+// auditSecretFields are never written to the audit log, only noted as changed
+// under the given name. They are keyed by Go field name, since they are hidden
+// from JSON.
+var auditSecretFields = map[string]string{"Password": "password"}
+
+// auditIgnoredFields change on every save and say nothing about what was done
+var auditIgnoredFields = map[string]bool{"updated_at": true}
+
+// AuditEvent is what a caller knows about an action; RecordAudit adds the time and hashes
+type AuditEvent struct {
+	Action     string
+	ActorID    *uint
+	TargetType string
+	TargetID   string
+	IP         string
+	RequestID  string
+	Details    map[string]interface{}
+}
+
+// auditHash is the SHA-256 of the entry's fields and the previous hash
+func auditHash(e models.AuditEntry) string {
+	actor := ""
+	if e.ActorID != nil {
+		actor = fmt.Sprint(*e.ActorID)
+	}
+	fields := []string{
+		e.PrevHash,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.Action, actor, e.TargetType, e.TargetID, e.IP, e.RequestID, e.Details,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordAudit appends an entry to the audit log, chained to the previous one
+func RecordAudit(db *gorm.DB, event AuditEvent) (models.AuditEntry, error) {
+	details := "{}"
+	if len(event.Details) > 0 {
+		encoded, err := json.Marshal(event.Details)
+		if err != nil {
+			return models.AuditEntry{}, err
+		}
+		details = string(encoded)
+	}
+	entry := models.AuditEntry{
+		// Stored with millisecond precision, so hash what will be read back
+		CreatedAt:  time.Now().UTC().Truncate(time.Millisecond),
+		Action:     event.Action,
+		ActorID:    event.ActorID,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.IP,
+		RequestID:  event.RequestID,
+		Details:    details,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.AuditChainHead{ID: auditHeadID}).Error; err != nil {
+			return err
+		}
+		var head models.AuditChainHead
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, auditHeadID).Error; err != nil {
+			return err
+		}
+		entry.PrevHash = head.Hash
+		entry.Hash = auditHash(entry)
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		return tx.Model(&head).Updates(map[string]interface{}{"last_id": entry.ID, "hash": entry.Hash, "entries": head.Entries + 1}).Error
+	})
+	return entry, err
+}
+
+// AuditDiff lists the fields that differ between two values of the same struct
+// type by their JSON names, as {"field": {"from": old, "to": new}}. Secret
+// fields only show that they changed.
+func AuditDiff(before, after interface{}) map[string]interface{} {
+	diff := map[string]interface{}{}
+	b, a := reflect.ValueOf(before), reflect.ValueOf(after)
+	if b.Type() != a.Type() || b.Kind() != reflect.Struct {
+		return diff
+	}
+	for i := 0; i < b.NumField(); i++ {
+		field := b.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		from, to := b.Field(i).Interface(), a.Field(i).Interface()
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		if secret, ok := auditSecretFields[field.Name]; ok {
+			diff[secret] = map[string]interface{}{"changed": true}
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || auditIgnoredFields[name] {
+			continue
+		}
+		diff[name] = map[string]interface{}{"from": from, "to": to}
+	}
+	return diff
+}
+
+// AuditVerification is the outcome of checking the audit chain
+type AuditVerification struct {
+	Checked int64 `json:"checked"`
+	Valid   bool  `json:"valid"`
+	// BrokenAt is the first entry whose hash or link does not match
+	BrokenAt uint   `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// VerifyAuditChain recomputes every entry's hash and link, oldest first, and
+// checks the newest matches the chain head so removed trailing entries show up too
+func VerifyAuditChain(db *gorm.DB) (AuditVerification, error) {
+	result := AuditVerification{Valid: true}
+	prev := ""
+	var lastID uint
+	var entries []models.AuditEntry
+	err := db.Order("id").FindInBatches(&entries, 500, func(tx *gorm.DB, batch int) error {
+		for _, e := range entries {
+			if !result.Valid {
+				return nil
+			}
+			result.Checked++
+			switch {
+			case e.PrevHash != prev:
+				result.Valid, result.BrokenAt, result.Reason = false, e.ID, "previous hash does not match the entry before it"
+			case auditHash(e) != e.Hash:
+				result.Valid, result.BrokenAt, result.Reason = false, e.ID, "entry does not match its hash"
+			}
+			prev, lastID = e.Hash, e.ID
+		}
+		return nil
+	}).Error
+	if err != nil || !result.Valid {
+		return result, err
+	}
+	var head models.AuditChainHead
+	if err := db.Limit(1).Find(&head, auditHeadID).Error; err != nil {
+		return result, err
+	}
+	if head.Hash != prev || head.LastID != lastID || head.Entries != result.Checked {
+		result.Valid, result.BrokenAt, result.Reason = false, lastID, "chain head does not match the newest entry; entries may have been removed"
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package services_test
+
+import (
+	"reflect"
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"gorm.io/gorm"
+)
+
+func TestVerifyAuditChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(t *testing.T, db *gorm.DB, entries []models.AuditEntry)
+		brokenAt int // index into entries, or -1 when the chain is intact
+	}{
+		{"intact", func(*testing.T, *gorm.DB, []models.AuditEntry) {}, -1},
+		{"edited entry", func(t *testing.T, db *gorm.DB, entries []models.AuditEntry) {
+			db.Model(&entries[1]).UpdateColumn("target_id", "mallory")
+		}, 1},
+		{"edited entry rehashed", func(t *testing.T, db *gorm.DB, entries []models.AuditEntry) {
+			edited := entries[1]
+			edited.TargetID = "mallory"
+			db.Model(&edited).UpdateColumns(map[string]interface{}{"target_id": edited.TargetID, "hash": services.AuditHash(edited)})
+		}, 2},
+		{"entry removed", func(t *testing.T, db *gorm.DB, entries []models.AuditEntry) {
+			db.Delete(&models.AuditEntry{}, entries[1].ID)
+		}, 2},
+		{"newest entry removed", func(t *testing.T, db *gorm.DB, entries []models.AuditEntry) {
+			db.Delete(&models.AuditEntry{}, entries[2].ID)
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			var entries []models.AuditEntry
+			for _, target := range []string{"alice", "bob", "carol"} {
+				entry, err := services.RecordAudit(db, services.AuditEvent{
+					Action:     services.AuditLoginFailure,
+					TargetType: "username",
+					TargetID:   target,
+					Details:    map[string]interface{}{"step": "password"},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				entries = append(entries, entry)
+			}
+			tt.tamper(t, db, entries)
+
+			result, err := services.VerifyAuditChain(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.brokenAt < 0 {
+				if !result.Valid || result.Checked != 3 {
+					t.Errorf("result = %+v, want 3 valid entries", result)
+				}
+				return
+			}
+			if result.Valid || result.BrokenAt != entries[tt.brokenAt].ID {
+				t.Errorf("result = %+v, want broken at entry %d", result, entries[tt.brokenAt].ID)
+			}
+		})
+	}
+}
+
+func TestAuditDiffHidesSecrets(t *testing.T) {
+	before := models.User{FirstName: "Jane", Password: "old-hash"}
+	after := before
+	after.FirstName, after.Password = "Janet", "new-hash"
+	diff := services.AuditDiff(before, after)
+	want := map[string]interface{}{
+		"first_name": map[string]interface{}{"from": "Jane", "to": "Janet"},
+		"password":   map[string]interface{}{"changed": true},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("AuditDiff() = %v, want %v", diff, want)
+	}
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RequireVerifiedContact blocks transfers from users without a verified email
+// or phone. Set from REQUIRE_VERIFIED_CONTACT at startup.
+var RequireVerifiedContact = false
+
+// VerificationCodeTTL is how long a contact verification code can be used
+var VerificationCodeTTL = 15 * time.Minute
+
+// VerificationMaxAttempts is how many wrong codes use up a verification code
+var VerificationMaxAttempts = 5
+
+// VerificationResendCooldown is how long a user must wait between codes on one channel
+var VerificationResendCooldown = time.Minute
+
+// VerificationMaxAttemptsPerContact caps wrong codes on one channel within
+// VerificationAttemptWindow, however many codes were sent, so requesting fresh
+// codes does not buy more guesses
+var VerificationMaxAttemptsPerContact = 10
+
+// VerificationAttemptWindow is the period VerificationMaxAttemptsPerContact counts over
+var VerificationAttemptWindow = 24 * time.Hour
+
+var (
+	// ErrInvalidContact is returned for a malformed email address or phone number
+	ErrInvalidContact = errors.New("invalid contact")
+	// ErrContactTaken is returned when another user already has the email address or phone number
+	ErrContactTaken = errors.New("contact already in use")
+	// ErrNoContact is returned when the user has no contact on the channel to verify
+	ErrNoContact = errors.New("no contact to verify")
+	// ErrContactVerified is returned when the contact is already verified
+	ErrContactVerified = errors.New("contact already verified")
+	// ErrInvalidVerificationCode is returned for a wrong, used up or expired code
+	ErrInvalidVerificationCode = errors.New("invalid or expired verification code")
+	// ErrVerificationCooldown is returned when a code was sent less than VerificationResendCooldown ago
+	ErrVerificationCooldown = errors.New("a code was sent recently, wait before requesting another")
+	// ErrVerificationLocked is returned when the channel has used up its wrong attempts for now
+	ErrVerificationLocked = errors.New("too many wrong codes, try again later")
+)
+
+// phonePattern is the E.164 format: a plus, a country code and up to 15 digits
+var phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// contactColumns are the user columns holding a channel's value and verification time
+var contactColumns = map[string][2]string{
+	models.ContactEmail: {"email", "email_verified_at"},
+	models.ContactPhone: {"phone", "phone_verified_at"},
+}
+
+// NormaliseContact validates a contact and returns it in its stored form
+func NormaliseContact(channel, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch channel {
+	case models.ContactEmail:
+		value = strings.ToLower(value)
+		address, err := mail.ParseAddress(value)
+		if err != nil || address.Address != value || len(value) > 254 {
+			return "", fmt.Errorf("%w: not a valid email address", ErrInvalidContact)
+		}
+		return value, nil
+	case models.ContactPhone:
+		value = strings.NewReplacer(" ", "", "-", "").Replace(value)
+		if !phonePattern.MatchString(value) {
+			return "", fmt.Errorf("%w: phone must be in E.164 format, e.g. +66812345678", ErrInvalidContact)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: unknown channel %q", ErrInvalidContact, channel)
+}
+
+// contactOf returns the user's current value and verification time on a channel
+func contactOf(user models.User, channel string) (string, *time.Time) {
+	switch channel {
+	case models.ContactEmail:
+		if user.Email != nil {
+			return *user.Email, user.EmailVerifiedAt
+		}
+	case models.ContactPhone:
+		if user.Phone != nil {
+			return *user.Phone, user.PhoneVerifiedAt
+		}
+	}
+	return "", nil
+}
+
+// HasVerifiedContact reports whether the user verified an email address or phone number
+func HasVerifiedContact(user models.User) bool {
+	return user.EmailVerifiedAt != nil || user.PhoneVerifiedAt != nil
+}
+
+// PreferredContact is the verified channel and address to notify a user on, if any
+func PreferredContact(user models.User) (string, string) {
+	for _, channel := range []string{models.ContactEmail, models.ContactPhone} {
+		if value, verifiedAt := contactOf(user, channel); verifiedAt != nil {
+			return channel, value
+		}
+	}
+	return "", ""
+}
+
+// ChangeContact sets a new email address or phone number, marks it unverified
+// and sends a verification code to it. Setting the current value again is a no-op.
+func ChangeContact(db *gorm.DB, user *models.User, channel, value string) error {
+	normalised, err := NormaliseContact(channel, value)
+	if err != nil {
+		return err
+	}
+	if current, _ := contactOf(*user, channel); current == normalised {
+		return nil
+	}
+	columns := contactColumns[channel]
+	var taken int64
+	if err := db.Unscoped().Model(&models.User{}).Where(columns[0]+" = ? AND id <> ?", normalised, user.ID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrContactTaken
+	}
+	if err := db.Model(user).Updates(map[string]interface{}{columns[0]: normalised, columns[1]: nil}).Error; err != nil {
+		// The unique index catches a concurrent claim of the same value
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrContactTaken
+		}
+		return err
+	}
+	if channel == models.ContactEmail {
+		user.Email, user.EmailVerifiedAt = &normalised, nil
+	} else {
+		user.Phone, user.PhoneVerifiedAt = &normalised, nil
+	}
+	return SendVerificationCode(db, *user, channel, time.Now())
+}
+
+// verificationAttempts counts wrong codes entered on a user's channel since since,
+// across every code sent in that time
+func verificationAttempts(db *gorm.DB, userID uint, channel string, since time.Time) (int64, error) {
+	var attempts int64
+	err := db.Model(&models.ContactVerification{}).Select("COALESCE(SUM(attempts), 0)").
+		Where("user_id = ? AND channel = ? AND created_at > ?", userID, channel, since).Scan(&attempts).Error
+	return attempts, err
+}
+
+// SendVerificationCode sends a fresh one-time code to the user's contact on channel.
+// Earlier codes for the channel stop working. Codes are rate limited by
+// VerificationResendCooldown, and none are sent while the channel is locked
+// by VerificationMaxAttemptsPerContact.
+func SendVerificationCode(db *gorm.DB, user models.User, channel string, now time.Time) error {
+	if _, ok := contactColumns[channel]; !ok {
+		return fmt.Errorf("%w: unknown channel %q", ErrInvalidContact, channel)
+	}
+	value, verifiedAt := contactOf(user, channel)
+	if value == "" {
+		return ErrNoContact
+	}
+	if verifiedAt != nil {
+		return ErrContactVerified
+	}
+	var last models.ContactVerification
+	err := db.Where("user_id = ? AND channel = ?", user.ID, channel).Order("id DESC").First(&last).Error
+	if err == nil && now.Sub(last.CreatedAt) < VerificationResendCooldown {
+		return ErrVerificationCooldown
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	attempts, err := verificationAttempts(db, user.ID, channel, now.Add(-VerificationAttemptWindow))
+	if err != nil {
+		return err
+	}
+	if attempts >= int64(VerificationMaxAttemptsPerContact) {
+		return ErrVerificationLocked
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Earlier codes are expired rather than deleted, so their wrong attempts still count
+		if err := tx.Model(&models.ContactVerification{}).Where("user_id = ? AND channel = ? AND verified_at IS NULL AND expires_at > ?", user.ID, channel, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.ContactVerification{
+			UserID:    user.ID,
+			Channel:   channel,
+			Value:     value,
+			CodeHash:  string(hash),
+			ExpiresAt: now.Add(VerificationCodeTTL),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return Notify.Send(Notification{
+		UserID:   user.ID,
+		Username: user.Username,
+		Channel:  channel,
+		To:       value,
+		Subject:  "Verification code",
+		Body:     fmt.Sprintf("Your verification code is %s. It expires in %s.", code, VerificationCodeTTL),
+	})
+}
+
+// VerifyContact checks a code and marks the contact verified. A code only
+// verifies the value it was sent to, so changing the contact meanwhile voids it.
+// The user's first verified contact makes them eligible for sign-up campaigns,
+// which are granted in the same transaction.
+func VerifyContact(db *gorm.DB, userID uint, channel, code string, now time.Time) error {
+	columns, ok := contactColumns[channel]
+	if !ok {
+		return fmt.Errorf("%w: unknown channel %q", ErrInvalidContact, channel)
+	}
+	var result error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return lookupError(err)
+		}
+		value, verifiedAt := contactOf(user, channel)
+		if value == "" {
+			result = ErrNoContact
+			return nil
+		}
+		if verifiedAt != nil {
+			result = ErrContactVerified
+			return nil
+		}
+		attempts, err := verificationAttempts(tx, userID, channel, now.Add(-VerificationAttemptWindow))
+		if err != nil {
+			return err
+		}
+		if attempts >= int64(VerificationMaxAttemptsPerContact) {
+			result = ErrVerificationLocked
+			return nil
+		}
+		var pending models.ContactVerification
+		err = tx.Where("user_id = ? AND channel = ? AND value = ? AND verified_at IS NULL AND expires_at > ? AND attempts < ?",
+			userID, channel, value, now, VerificationMaxAttempts).Order("id DESC").First(&pending).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result = ErrInvalidVerificationCode
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(pending.CodeHash), []byte(strings.TrimSpace(code))) != nil {
+			result = ErrInvalidVerificationCode
+			return tx.Model(&pending).Update("attempts", pending.Attempts+1).Error
+		}
+		if err := tx.Model(&pending).Update("verified_at", now).Error; err != nil {
+			return err
+		}
+		// A contact verified earlier already had its chance at the sign-up campaigns
+		firstContact := !HasVerifiedContact(user)
+		if err := tx.Model(&user).Update(columns[1], now).Error; err != nil {
+			return err
+		}
+		if !firstContact {
+			return nil
+		}
+		// A failed grant does not undo the verification; startup retries it
+		if _, err := GrantSignupPromotions(tx, user); err != nil {
+			log.Printf("Could not grant sign-up promotions to user %d: %v", user.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerificationCodeResendCooldown(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.CreateUser(t, db, "dana", "123456789", 0)
+	if err := services.ChangeContact(db, &user, models.ContactEmail, "dana@example.com"); err != nil {
+		t.Fatalf("ChangeContact: %v", err)
+	}
+	if err := services.SendVerificationCode(db, user, models.ContactEmail, time.Now()); !errors.Is(err, services.ErrVerificationCooldown) {
+		t.Fatalf("immediate resend: err = %v, want ErrVerificationCooldown", err)
+	}
+	if err := services.SendVerificationCode(db, user, models.ContactEmail, time.Now().Add(services.VerificationResendCooldown)); err != nil {
+		t.Fatalf("resend after cooldown: %v", err)
+	}
+}
+
+func TestVerificationAttemptsCountAcrossCodes(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.CreateUser(t, db, "erin", "123456789", 0)
+	if err := services.ChangeContact(db, &user, models.ContactEmail, "erin@example.com"); err != nil {
+		t.Fatalf("ChangeContact: %v", err)
+	}
+	now := time.Now()
+	// Spend the per-contact budget a few guesses per code, requesting a new code each time
+	for wrong := 0; wrong < services.VerificationMaxAttemptsPerContact; wrong++ {
+		if wrong > 0 && wrong%2 == 0 {
+			now = now.Add(services.VerificationResendCooldown)
+			if err := services.SendVerificationCode(db, user, models.ContactEmail, now); err != nil {
+				t.Fatalf("resend %d: %v", wrong, err)
+			}
+		}
+		if err := services.VerifyContact(db, user.ID, models.ContactEmail, "not-a-code", now); !errors.Is(err, services.ErrInvalidVerificationCode) {
+			t.Fatalf("guess %d: err = %v, want ErrInvalidVerificationCode", wrong, err)
+		}
+	}
+	now = now.Add(services.VerificationResendCooldown)
+	if err := services.SendVerificationCode(db, user, models.ContactEmail, now); !errors.Is(err, services.ErrVerificationLocked) {
+		t.Errorf("send after cap: err = %v, want ErrVerificationLocked", err)
+	}
+	if err := services.VerifyContact(db, user.ID, models.ContactEmail, "000000", now); !errors.Is(err, services.ErrVerificationLocked) {
+		t.Errorf("verify after cap: err = %v, want ErrVerificationLocked", err)
+	}
+}
+
+func TestSignupPromotionWaitsForVerifiedContact(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.CreateUser(t, db, "farah", "123456789", 0)
+	if granted, err := services.GrantMissedSignupPromotions(db); err != nil || granted != 0 {
+		t.Fatalf("unverified backfill: granted %d, err %v; want nothing", granted, err)
+	}
+	if err := services.ChangeContact(db, &user, models.ContactPhone, "+66812345678"); err != nil {
+		t.Fatalf("ChangeContact: %v", err)
+	}
+	// Swap the sent code for a known one so the test can enter it
+	hash, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&models.ContactVerification{}).Where("user_id = ?", user.ID).Update("code_hash", string(hash)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := services.VerifyContact(db, user.ID, models.ContactPhone, "123456", time.Now()); err != nil {
+		t.Fatalf("VerifyContact: %v", err)
+	}
+	if credit := testutil.Credit(t, db, user.ID); credit != models.LegacyOpeningBalance {
+		t.Errorf("credit after verifying = %.2f, want the welcome bonus %.2f", credit, models.LegacyOpeningBalance)
+	}
+	if granted, err := services.GrantMissedSignupPromotions(db); err != nil || granted != 0 {
+		t.Errorf("backfill after grant: granted %d, err %v; want nothing", granted, err)
+	}
+}
@@ -0,0 +1,9 @@
+package services
+
+// Unexported pieces the services_test package exercises directly
+var (
+	AuditHash        = auditHash
+	CRC16            = crc16
+	NextInterestDays = nextInterestDays
+	RunInterestFrom  = runInterest
+)
@@ -0,0 +1,136 @@
+// Package fakerail is an in-process payment rail for tests and local
+// development. It accepts every payment, then reports the outcome a moment
+// later through a signed callback, the way a real rail would.
+package fakerail
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"gotestbackend/models"
+	"gotestbackend/services"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the callback body
+const SignatureHeader = "X-Fake-Rail-Signature"
+
+// Callback is the body the rail posts back
+type Callback struct {
+	Reference     string `json:"reference"`
+	ExternalID    string `json:"external_id"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// Rail is the fake rail. Payments whose Fail returns a reason fail with it;
+// all others succeed. With CallbackURL empty no callback is sent, and tests
+// deliver one themselves with Sign.
+type Rail struct {
+	Secret      []byte
+	CallbackURL string
+	Delay       time.Duration
+	Fail        func(p services.RailPayment) string
+}
+
+// New returns a rail that calls back to callbackURL after delay
+func New(secret []byte, callbackURL string, delay time.Duration) *Rail {
+	return &Rail{Secret: secret, CallbackURL: callbackURL, Delay: delay}
+}
+
+// InitiateDeposit accepts the deposit and settles it through a callback
+func (r *Rail) InitiateDeposit(p services.RailPayment) (services.RailResult, error) {
+	return r.initiate(p)
+}
+
+// InitiateWithdrawal accepts the withdrawal and settles it through a callback
+func (r *Rail) InitiateWithdrawal(p services.RailPayment) (services.RailResult, error) {
+	return r.initiate(p)
+}
+
+func (r *Rail) initiate(p services.RailPayment) (services.RailResult, error) {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return services.RailResult{}, err
+	}
+	callback := Callback{Reference: p.Reference, ExternalID: "fake_" + hex.EncodeToString(raw), Status: models.PaymentSucceeded}
+	if r.Fail != nil {
+		if reason := r.Fail(p); reason != "" {
+			callback.Status, callback.FailureReason = models.PaymentFailed, reason
+		}
+	}
+	if r.CallbackURL != "" {
+		go r.deliver(callback)
+	}
+	return services.RailResult{ExternalID: callback.ExternalID, Status: models.PaymentPending}, nil
+}
+
+// deliver posts the callback after the rail's delay
+func (r *Rail) deliver(callback Callback) {
+	time.Sleep(r.Delay)
+	body, signature, err := r.Sign(callback)
+	if err != nil {
+		log.Printf("Fake rail: could not encode callback %s: %v", callback.Reference, err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, r.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Fake rail: bad callback URL: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Fake rail: callback %s failed: %v", callback.Reference, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Fake rail: callback %s answered %s", callback.Reference, resp.Status)
+	}
+}
+
+// Sign encodes a callback and signs it as the rail does
+func (r *Rail) Sign(callback Callback) ([]byte, string, error) {
+	body, err := json.Marshal(callback)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, hex.EncodeToString(r.mac(body)), nil
+}
+
+// mac is the HMAC-SHA256 of body under the rail's secret
+func (r *Rail) mac(body []byte) []byte {
+	mac := hmac.New(sha256.New, r.Secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// ParseCallback checks the signature header and decodes the callback
+func (r *Rail) ParseCallback(header http.Header, body []byte) (services.RailCallback, error) {
+	signature, err := hex.DecodeString(header.Get(SignatureHeader))
+	if err != nil || !hmac.Equal(signature, r.mac(body)) {
+		return services.RailCallback{}, services.ErrInvalidCallbackSignature
+	}
+	var callback Callback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		return services.RailCallback{}, fmt.Errorf("%w: %v", services.ErrInvalidCallback, err)
+	}
+	if callback.Reference == "" {
+		return services.RailCallback{}, fmt.Errorf("%w: reference is required", services.ErrInvalidCallback)
+	}
+	return services.RailCallback{
+		Reference:     callback.Reference,
+		ExternalID:    callback.ExternalID,
+		Status:        callback.Status,
+		FailureReason: callback.FailureReason,
+	}, nil
+}
@@ -0,0 +1,42 @@
+package fakerail
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"gotestbackend/models"
+	"gotestbackend/services"
+)
+
+func TestSignedCallbackParses(t *testing.T) {
+	rail := New([]byte("secret"), "", 0)
+	body, signature, err := rail.Sign(Callback{Reference: "ref-1", ExternalID: "ext-1", Status: models.PaymentSucceeded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback, err := rail.ParseCallback(http.Header{SignatureHeader: {signature}}, body)
+	if err != nil {
+		t.Fatalf("ParseCallback: %v", err)
+	}
+	if callback.Reference != "ref-1" || callback.ExternalID != "ext-1" || callback.Status != models.PaymentSucceeded {
+		t.Errorf("callback = %+v", callback)
+	}
+}
+
+func TestCallbackWithWrongSignatureIsRejected(t *testing.T) {
+	rail := New([]byte("secret"), "", 0)
+	forger := New([]byte("guessed"), "", 0)
+	body, signature, err := forger.Sign(Callback{Reference: "ref-1", Status: models.PaymentSucceeded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rail.ParseCallback(http.Header{SignatureHeader: {signature}}, body); !errors.Is(err, services.ErrInvalidCallbackSignature) {
+		t.Fatalf("err = %v, want ErrInvalidCallbackSignature", err)
+	}
+	// A body changed after signing fails too
+	_, signature, _ = rail.Sign(Callback{Reference: "ref-1", Status: models.PaymentFailed})
+	if _, err := rail.ParseCallback(http.Header{SignatureHeader: {signature}}, body); !errors.Is(err, services.ErrInvalidCallbackSignature) {
+		t.Fatalf("tampered body: err = %v, want ErrInvalidCallbackSignature", err)
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidFeeSchedule is returned when a fee schedule is missing the fields its kind needs
+var ErrInvalidFeeSchedule = errors.New("invalid fee schedule")
+
+// roundMoney rounds to two decimal places
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// ValidateFeeSchedule checks a schedule before it is saved
+func ValidateFeeSchedule(s models.FeeSchedule) error {
+	switch s.Kind {
+	case models.FeeFlat:
+		if s.FlatAmount < 0 {
+			return fmt.Errorf("%w: flat_amount must not be negative", ErrInvalidFeeSchedule)
+		}
+	case models.FeePercentage:
+		if s.Percentage < 0 || s.Percentage > 100 {
+			return fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidFeeSchedule)
+		}
+	case models.FeeTiered:
+		if len(s.Tiers) == 0 {
+			return fmt.Errorf("%w: tiered schedules need at least one tier", ErrInvalidFeeSchedule)
+		}
+	default:
+		return fmt.Errorf("%w: kind must be flat, percentage or tiered", ErrInvalidFeeSchedule)
+	}
+	if s.AccountType != models.AccountTypePersonal && s.AccountType != models.AccountTypeBusiness {
+		return fmt.Errorf("%w: account_type must be personal or business", ErrInvalidFeeSchedule)
+	}
+	if s.MinFee < 0 || s.MaxFee < 0 || (s.MaxFee > 0 && s.MinFee > s.MaxFee) {
+		return fmt.Errorf("%w: min_fee and max_fee must be non-negative and min_fee <= max_fee", ErrInvalidFeeSchedule)
+	}
+	if s.FreeTransfersPerMonth < 0 {
+		return fmt.Errorf("%w: free_transfers_per_month must not be negative", ErrInvalidFeeSchedule)
+	}
+	return nil
+}
+
+// ScheduleFee prices one transfer with a schedule, ignoring the free allowance
+func ScheduleFee(s models.FeeSchedule, amount float64) float64 {
+	var fee float64
+	switch s.Kind {
+	case models.FeeFlat:
+		fee = s.FlatAmount
+	case models.FeePercentage:
+		fee = amount * s.Percentage / 100
+	case models.FeeTiered:
+		for _, tier := range s.Tiers {
+			if tier.UpToAmount == 0 || amount <= tier.UpToAmount {
+				fee = tier.FlatAmount + amount*tier.Percentage/100
+				break
+			}
+		}
+	}
+	if s.MinFee > 0 && fee < s.MinFee {
+		fee = s.MinFee
+	}
+	if s.MaxFee > 0 && fee > s.MaxFee {
+		fee = s.MaxFee
+	}
+	return roundMoney(fee)
+}
+
+// TransferFee prices a transfer for a sender using the active schedule of their
+// account type, after the schedule's free monthly transfers are used up
+func TransferFee(db *gorm.DB, sender models.User, amount float64, now time.Time) (float64, error) {
+	accountType := sender.AccountType
+	if accountType == "" {
+		accountType = models.AccountTypePersonal
+	}
+	var schedule models.FeeSchedule
+	err := db.Preload("Tiers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("up_to_amount = 0, up_to_amount")
+	}).Where("account_type = ? AND active = ?", accountType, true).Order("id DESC").First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if schedule.FreeTransfersPerMonth > 0 {
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		var sent int64
+		if err := db.Model(&models.Transaction{}).
+			Where("sender_id = ? AND kind = ? AND created_at >= ?", sender.ID, models.TransactionTransfer, monthStart).
+			Count(&sent).Error; err != nil {
+			return 0, err
+		}
+		if sent < int64(schedule.FreeTransfersPerMonth) {
+			return 0, nil
+		}
+	}
+	return ScheduleFee(schedule, amount), nil
+}
@@ -0,0 +1,83 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+)
+
+func TestScheduleFee(t *testing.T) {
+	tiered := models.FeeSchedule{Kind: models.FeeTiered, Tiers: []models.FeeTier{
+		{UpToAmount: 1000, FlatAmount: 0},
+		{UpToAmount: 10000, FlatAmount: 5},
+		{UpToAmount: 0, FlatAmount: 10, Percentage: 0.1},
+	}}
+	tests := []struct {
+		name     string
+		schedule models.FeeSchedule
+		amount   float64
+		want     float64
+	}{
+		{"flat", models.FeeSchedule{Kind: models.FeeFlat, FlatAmount: 2.5}, 100, 2.5},
+		{"percentage rounds to cents", models.FeeSchedule{Kind: models.FeePercentage, Percentage: 0.5}, 123.45, 0.62},
+		{"percentage below minimum", models.FeeSchedule{Kind: models.FeePercentage, Percentage: 0.5, MinFee: 1}, 100, 1},
+		{"percentage capped", models.FeeSchedule{Kind: models.FeePercentage, Percentage: 0.5, MaxFee: 20}, 10000, 20},
+		{"tier bound is inclusive", tiered, 1000, 0},
+		{"second tier", tiered, 1000.01, 5},
+		{"open-ended tier", tiered, 50000, 60},
+		{"tiered capped", models.FeeSchedule{Kind: models.FeeTiered, Tiers: tiered.Tiers, MaxFee: 25}, 50000, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := services.ScheduleFee(tt.schedule, tt.amount); got != tt.want {
+				t.Errorf("ScheduleFee(%v) = %v, want %v", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferFeeMonthlyAllowance(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.CreateUser(t, db, "sender", "123456789", 1000)
+	receiver := testutil.CreateUser(t, db, "receiver", "234567891", 0)
+	// Tiers saved out of order are still priced lowest band first
+	schedule := models.FeeSchedule{
+		AccountType:           models.AccountTypePersonal,
+		Kind:                  models.FeeTiered,
+		FreeTransfersPerMonth: 2,
+		Active:                true,
+		Tiers:                 []models.FeeTier{{UpToAmount: 0, FlatAmount: 10}, {UpToAmount: 100, FlatAmount: 1}},
+	}
+	if err := db.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// A transfer from last month does not use this month's allowance
+	old := postTransfers(t, db, sender, receiver, 5)
+	if err := db.Model(&old[0]).Update("created_at", time.Now().AddDate(0, -1, 0)).Error; err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sentThisMonth int
+		amount        float64
+		want          float64
+	}{
+		{0, 50, 0},
+		{1, 50, 0},
+		{2, 50, 1},
+		{3, 500, 10},
+	}
+	for _, tt := range tests {
+		got, err := services.TransferFee(db, sender, tt.amount, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("after %d transfers, fee for %v = %v, want %v", tt.sentThisMonth, tt.amount, got, tt.want)
+		}
+		postTransfers(t, db, sender, receiver, 5)
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+)
+
+// Fraud decisions, from least to most severe
+const (
+	FraudAllow     = "allow"
+	FraudChallenge = "challenge"
+	FraudBlock     = "block"
+)
+
+var fraudSeverity = map[string]int{FraudAllow: 0, FraudChallenge: 1, FraudBlock: 2}
+
+var (
+	// ErrReviewNotPending is returned when a review was already approved or rejected
+	ErrReviewNotPending = errors.New("review is not pending")
+	// ErrOwnReview is returned when an admin tries to decide a transfer they sent or would receive
+	ErrOwnReview = errors.New("admins cannot decide reviews of their own transfers")
+)
+
+// FraudRuleSettings tunes the built-in fraud rules
+type FraudRuleSettings struct {
+	// Velocity: more than VelocityMaxTransfers sent within VelocityWindow
+	VelocityMaxTransfers int
+	VelocityWindow       time.Duration
+	VelocityAction       string
+	// First-time receiver: nothing sent to this receiver before and amount at least NewReceiverAmount
+	NewReceiverAmount float64
+	NewReceiverAction string
+	// Unusual amount: amount above HistoryMultiplier times the sender's average,
+	// once they have at least HistoryMinTransfers transfers
+	HistoryMultiplier   float64
+	HistoryMinTransfers int
+	HistoryAction       string
+	// Funnelling: receiver got money from at least FunnelMaxSenders distinct senders within FunnelWindow
+	FunnelMaxSenders int
+	FunnelWindow     time.Duration
+	FunnelAction     string
+}
+
+// ValidateFraudRules checks rule settings before they are used. A zero
+// threshold switches its rule off; negative ones and unknown actions are refused.
+func ValidateFraudRules(rules FraudRuleSettings) error {
+	for _, rule := range [][2]string{
+		{"velocity", rules.VelocityAction}, {"new receiver", rules.NewReceiverAction},
+		{"history", rules.HistoryAction}, {"funnel", rules.FunnelAction},
+	} {
+		if _, ok := fraudSeverity[rule[1]]; !ok {
+			return fmt.Errorf("%s action must be allow, challenge or block, not %q", rule[0], rule[1])
+		}
+	}
+	if rules.VelocityMaxTransfers < 0 || rules.HistoryMinTransfers < 0 || rules.FunnelMaxSenders < 0 ||
+		rules.NewReceiverAmount < 0 || rules.HistoryMultiplier < 0 {
+		return errors.New("fraud rule thresholds must not be negative")
+	}
+	if (rules.VelocityMaxTransfers > 0 && rules.VelocityWindow <= 0) || (rules.FunnelMaxSenders > 0 && rules.FunnelWindow <= 0) {
+		return errors.New("fraud rule windows must be positive")
+	}
+	return nil
+}
+
+// FraudRules holds the settings every transfer is checked against. Set from
+// the FRAUD_* variables at startup.
+var FraudRules = FraudRuleSettings{
+	VelocityMaxTransfers: 5,
+	VelocityWindow:       10 * time.Minute,
+	VelocityAction:       FraudBlock,
+	NewReceiverAmount:    10000,
+	NewReceiverAction:    FraudChallenge,
+	HistoryMultiplier:    5,
+	HistoryMinTransfers:  3,
+	HistoryAction:        FraudChallenge,
+	FunnelMaxSenders:     10,
+	FunnelWindow:         time.Hour,
+	FunnelAction:         FraudChallenge,
+}
+
+// FraudHit is one rule that matched
+type FraudHit struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+	Detail string `json:"detail"`
+}
+
+// FraudAssessment is the combined outcome of every rule; the most severe action wins
+type FraudAssessment struct {
+	Decision string     `json:"decision"`
+	Hits     []FraudHit `json:"hits"`
+}
+
+func (a *FraudAssessment) add(rule, action, detail string) {
+	a.Hits = append(a.Hits, FraudHit{Rule: rule, Action: action, Detail: detail})
+	if fraudSeverity[action] > fraudSeverity[a.Decision] {
+		a.Decision = action
+	}
+}
+
+// AssessTransfer runs every fraud rule against a transfer that has not happened yet
+func AssessTransfer(db *gorm.DB, sender, receiver models.User, amount float64, now time.Time) (FraudAssessment, error) {
+	rules := FraudRules
+	assessment := FraudAssessment{Decision: FraudAllow, Hits: []FraudHit{}}
+	transfers := db.Model(&models.Transaction{}).Where("kind = ?", models.TransactionTransfer)
+
+	if rules.VelocityMaxTransfers > 0 {
+		var recent int64
+		if err := transfers.Session(&gorm.Session{}).Where("sender_id = ? AND created_at >= ?", sender.ID, now.Add(-rules.VelocityWindow)).
+			Count(&recent).Error; err != nil {
+			return assessment, err
+		}
+		if recent >= int64(rules.VelocityMaxTransfers) {
+			assessment.add("velocity", rules.VelocityAction,
+				fmt.Sprintf("%d transfers in the last %s", recent+1, rules.VelocityWindow))
+		}
+	}
+
+	if rules.NewReceiverAmount > 0 && amount >= rules.NewReceiverAmount {
+		var previous int64
+		if err := transfers.Session(&gorm.Session{}).Where("sender_id = ? AND receiver_id = ?", sender.ID, receiver.ID).
+			Count(&previous).Error; err != nil {
+			return assessment, err
+		}
+		if previous == 0 {
+			assessment.add("new_receiver_large_amount", rules.NewReceiverAction,
+				fmt.Sprintf("first transfer to %s is %.2f", receiver.AccountNumber, amount))
+		}
+	}
+
+	if rules.HistoryMultiplier > 0 {
+		var history struct {
+			Count   int64
+			Average float64
+		}
+		if err := transfers.Session(&gorm.Session{}).Select("COUNT(*) AS count, COALESCE(AVG(amount), 0) AS average").
+			Where("sender_id = ?", sender.ID).Scan(&history).Error; err != nil {
+			return assessment, err
+		}
+		if history.Count >= int64(rules.HistoryMinTransfers) && amount > history.Average*rules.HistoryMultiplier {
+			assessment.add("unusual_amount", rules.HistoryAction,
+				fmt.Sprintf("%.2f is more than %.0fx the average of %.2f", amount, rules.HistoryMultiplier, history.Average))
+		}
+	}
+
+	if rules.FunnelMaxSenders > 0 {
+		var senders int64
+		if err := transfers.Session(&gorm.Session{}).Where("receiver_id = ? AND sender_id <> ? AND created_at >= ?", receiver.ID, sender.ID, now.Add(-rules.FunnelWindow)).
+			Distinct("sender_id").Count(&senders).Error; err != nil {
+			return assessment, err
+		}
+		if senders+1 >= int64(rules.FunnelMaxSenders) {
+			assessment.add("funnelling", rules.FunnelAction,
+				fmt.Sprintf("%s received from %d senders in the last %s", receiver.AccountNumber, senders+1, rules.FunnelWindow))
+		}
+	}
+	return assessment, nil
+}
+
+// QueueFraudReview holds a flagged transfer for an admin to approve or reject.
+// A blocked transfer is recorded as already rejected, so it cannot be approved.
+func QueueFraudReview(db *gorm.DB, sender, receiver models.User, amount float64, assessment FraudAssessment) (models.FraudReview, error) {
+	rules := make([]string, 0, len(assessment.Hits))
+	for _, hit := range assessment.Hits {
+		rules = append(rules, fmt.Sprintf("%s (%s): %s", hit.Rule, hit.Action, hit.Detail))
+	}
+	review := models.FraudReview{
+		SenderID:        sender.ID,
+		ReceiverID:      receiver.ID,
+		ReceiverAccount: receiver.AccountNumber,
+		Amount:          amount,
+		Decision:        assessment.Decision,
+		Rules:           strings.Join(rules, "; "),
+		Status:          models.ReviewPending,
+	}
+	if assessment.Decision == FraudBlock {
+		now := time.Now()
+		review.Status = models.ReviewRejected
+		review.ReviewedAt = &now
+		review.ReviewNote = "Blocked by fraud rules"
+	}
+	err := db.Create(&review).Error
+	return review, err
+}
+
+// ClaimFraudReview moves a pending review to status, so only one admin can act
+// on it. Admins cannot decide transfers they are a party to.
+func ClaimFraudReview(db *gorm.DB, reviewID uint, status string, reviewedBy uint, note string) (models.FraudReview, error) {
+	var review models.FraudReview
+	if err := db.First(&review, reviewID).Error; err != nil {
+		return review, err
+	}
+	if reviewedBy == review.SenderID || reviewedBy == review.ReceiverID {
+		return review, ErrOwnReview
+	}
+	now := time.Now()
+	result := db.Model(&models.FraudReview{}).
+		Where("id = ? AND status = ?", reviewID, models.ReviewPending).
+		Updates(map[string]interface{}{"status": status, "reviewed_by": reviewedBy, "reviewed_at": now, "review_note": note})
+	if result.Error != nil {
+		return review, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return review, ErrReviewNotPending
+	}
+	review.Status = status
+	review.ReviewedBy = &reviewedBy
+	review.ReviewedAt = &now
+	review.ReviewNote = note
+	return review, nil
+}
+
+// ReopenFraudReview puts a claimed review back to pending, e.g. when executing the approved transfer failed
+func ReopenFraudReview(db *gorm.DB, reviewID uint) error {
+	return db.Model(&models.FraudReview{}).Where("id = ?", reviewID).
+		Updates(map[string]interface{}{"status": models.ReviewPending, "reviewed_by": nil, "reviewed_at": nil, "review_note": ""}).Error
+}
@@ -0,0 +1,138 @@
+package services_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"gorm.io/gorm"
+)
+
+func TestAssessTransfer(t *testing.T) {
+	velocity := services.FraudRuleSettings{VelocityMaxTransfers: 3, VelocityWindow: time.Hour, VelocityAction: services.FraudBlock}
+	newReceiver := services.FraudRuleSettings{NewReceiverAmount: 500, NewReceiverAction: services.FraudChallenge}
+	history := services.FraudRuleSettings{HistoryMultiplier: 5, HistoryMinTransfers: 3, HistoryAction: services.FraudChallenge}
+	funnel := services.FraudRuleSettings{FunnelMaxSenders: 3, FunnelWindow: time.Hour, FunnelAction: services.FraudChallenge}
+	both := velocity
+	both.NewReceiverAmount, both.NewReceiverAction = newReceiver.NewReceiverAmount, newReceiver.NewReceiverAction
+
+	type parties struct{ sender, receiver, other, third models.User }
+	tests := []struct {
+		name     string
+		rules    services.FraudRuleSettings
+		setup    func(t *testing.T, db *gorm.DB, p parties)
+		amount   float64
+		decision string
+		hits     []string
+	}{
+		{"velocity under the limit", velocity, func(t *testing.T, db *gorm.DB, p parties) {
+			postTransfers(t, db, p.sender, p.other, 10, 10)
+		}, 10, services.FraudAllow, nil},
+		{"velocity over the limit", velocity, func(t *testing.T, db *gorm.DB, p parties) {
+			postTransfers(t, db, p.sender, p.other, 10, 10, 10)
+		}, 10, services.FraudBlock, []string{"velocity"}},
+		{"large first transfer", newReceiver, nil, 500, services.FraudChallenge, []string{"new_receiver_large_amount"}},
+		{"large transfer to a known receiver", newReceiver, func(t *testing.T, db *gorm.DB, p parties) {
+			postTransfers(t, db, p.sender, p.receiver, 10)
+		}, 500, services.FraudAllow, nil},
+		{"small first transfer", newReceiver, nil, 499.99, services.FraudAllow, nil},
+		{"amount within history", history, func(t *testing.T, db *gorm.DB, p parties) {
+			postTransfers(t, db, p.sender, p.other, 10, 10, 10)
+		}, 50, services.FraudAllow, nil},
+		{"amount far above history", history, func(t *testing.T, db *gorm.DB, p parties) {
+			postTransfers(t, db, p.sender, p.other, 10, 10, 10)
+		}, 50.01, services.FraudChallenge, []string{"unusual_amount"}},
+		{"too little history", history, func(t *testing.T, db *gorm.DB, p parties) {
+			postTransfers(t, db, p.sender, p.other, 10, 10)
+		}, 1000, services.FraudAllow, nil},
+		{"few senders", funnel, func(t *testing.T, db *gorm.DB, p parties) {
+			postTransfers(t, db, p.other, p.receiver, 10)
+		}, 10, services.FraudAllow, nil},
+		{"funnelling", funnel, func(t *testing.T, db *gorm.DB, p parties) {
+			postTransfers(t, db, p.other, p.receiver, 10)
+			postTransfers(t, db, p.third, p.receiver, 10)
+		}, 10, services.FraudChallenge, []string{"funnelling"}},
+		{"most severe action wins", both, func(t *testing.T, db *gorm.DB, p parties) {
+			postTransfers(t, db, p.sender, p.other, 10, 10, 10)
+		}, 500, services.FraudBlock, []string{"velocity", "new_receiver_large_amount"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			previous := services.FraudRules
+			services.FraudRules = tt.rules
+			t.Cleanup(func() { services.FraudRules = previous })
+			p := parties{
+				sender:   testutil.CreateUser(t, db, "sender", "123456789", 1000),
+				receiver: testutil.CreateUser(t, db, "receiver", "234567891", 0),
+				other:    testutil.CreateUser(t, db, "other", "345678912", 1000),
+				third:    testutil.CreateUser(t, db, "third", "456789123", 1000),
+			}
+			if tt.setup != nil {
+				tt.setup(t, db, p)
+			}
+
+			got, err := services.AssessTransfer(db, p.sender, p.receiver, tt.amount, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rules []string
+			for _, hit := range got.Hits {
+				rules = append(rules, hit.Rule)
+			}
+			if got.Decision != tt.decision || strings.Join(rules, ",") != strings.Join(tt.hits, ",") {
+				t.Errorf("AssessTransfer() = %s %v, want %s %v", got.Decision, rules, tt.decision, tt.hits)
+			}
+		})
+	}
+}
+
+func TestClaimFraudReviewRefusesParties(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.CreateUser(t, db, "sender", "123456789", 500)
+	receiver := testutil.CreateUser(t, db, "receiver", "234567891", 0)
+	admin := testutil.CreateUser(t, db, "admin", "345678912", 0)
+	assessment := services.FraudAssessment{Decision: services.FraudChallenge}
+	review, err := services.QueueFraudReview(db, sender, receiver, 100, assessment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, party := range []models.User{sender, receiver} {
+		if _, err := services.ClaimFraudReview(db, review.ID, models.ReviewApproved, party.ID, ""); !errors.Is(err, services.ErrOwnReview) {
+			t.Errorf("claim by %s: err = %v, want ErrOwnReview", party.Username, err)
+		}
+	}
+	if _, err := services.ClaimFraudReview(db, review.ID, models.ReviewApproved, admin.ID, "ok"); err != nil {
+		t.Errorf("claim by another admin: %v", err)
+	}
+}
+
+func TestValidateFraudRules(t *testing.T) {
+	valid := services.FraudRules
+	tests := []struct {
+		name   string
+		change func(*services.FraudRuleSettings)
+		ok     bool
+	}{
+		{"defaults", func(*services.FraudRuleSettings) {}, true},
+		{"rule switched off", func(r *services.FraudRuleSettings) { r.VelocityMaxTransfers, r.VelocityWindow = 0, 0 }, true},
+		{"unknown action", func(r *services.FraudRuleSettings) { r.FunnelAction = "deny" }, false},
+		{"negative threshold", func(r *services.FraudRuleSettings) { r.NewReceiverAmount = -1 }, false},
+		{"missing window", func(r *services.FraudRuleSettings) { r.FunnelWindow = 0 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := valid
+			tt.change(&rules)
+			if err := services.ValidateFraudRules(rules); (err == nil) != tt.ok {
+				t.Errorf("ValidateFraudRules() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
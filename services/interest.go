@@ -0,0 +1,319 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidInterestProduct is returned when a product has a bad rate or day count
+var ErrInvalidInterestProduct = errors.New("invalid interest product")
+
+// ErrInvalidDateRange is returned when a job is asked to run over an empty range
+var ErrInvalidDateRange = errors.New("invalid date range")
+
+// ErrDayNotEnded is returned when interest is asked to run for today or later,
+// whose closing balances are not known yet
+var ErrDayNotEnded = errors.New("interest can only run for days that have ended")
+
+// InterestRunResult summarises one interest run
+type InterestRunResult struct {
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	AccrualsAdded  int      `json:"accruals_added"`
+	AccruedAmount  float64  `json:"accrued_amount"`
+	PostingsMade   int      `json:"postings_made"`
+	PostedAmount   float64  `json:"posted_amount"`
+	MonthsComplete []string `json:"months_complete"`
+}
+
+// ValidateInterestProduct checks a product before it is saved
+func ValidateInterestProduct(p models.InterestProduct) error {
+	if p.AnnualRate < 0 || p.AnnualRate > 100 {
+		return fmt.Errorf("%w: annual_rate must be between 0 and 100", ErrInvalidInterestProduct)
+	}
+	switch p.DayCount {
+	case models.DayCountActual365, models.DayCountActual360, models.DayCount30360:
+		return nil
+	}
+	return fmt.Errorf("%w: day_count must be ACT/365, ACT/360 or 30/360", ErrInvalidInterestProduct)
+}
+
+// truncateDay drops the time of day
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dayFraction is the fraction of a year one calendar day counts for
+func dayFraction(dayCount string, day time.Time) float64 {
+	switch dayCount {
+	case models.DayCountActual360:
+		return 1.0 / 360
+	case models.DayCount30360:
+		// Every month counts as 30 days: the 31st earns nothing and the
+		// last day of February makes up the missing days
+		lastOfMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+		switch {
+		case day.Day() == 31:
+			return 0
+		case day.Day() == lastOfMonth && lastOfMonth < 30:
+			return float64(30-lastOfMonth+1) / 360
+		}
+		return 1.0 / 360
+	}
+	return 1.0 / 365
+}
+
+// closingBalance is the user's credit at the end of day, replayed from the
+// remaining snapshots on their transactions
+func closingBalance(db *gorm.DB, user models.User, day time.Time) (float64, error) {
+	endOfDay := day.AddDate(0, 0, 1)
+	var last models.Transaction
+	err := db.Where("(sender_id = ? OR receiver_id = ?) AND created_at < ?", user.ID, user.ID, endOfDay).
+		Order("created_at DESC, id DESC").First(&last).Error
+	if err == nil {
+		if last.SenderID == user.ID {
+			return last.SenderRemaining, nil
+		}
+		return last.ReceiverRemaining, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	// No activity yet: the balance is what it was before the first later transaction
+	var next models.Transaction
+	err = db.Where("sender_id = ? OR receiver_id = ?", user.ID, user.ID).
+		Order("created_at, id").First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user.Credit, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if next.SenderID == user.ID {
+		return next.SenderRemaining + next.Amount, nil
+	}
+	return next.ReceiverRemaining - next.Amount, nil
+}
+
+// AccrueInterest records one day of interest for every account on an active product.
+// Days that already have an accrual are skipped, so it is safe to run again.
+func AccrueInterest(db *gorm.DB, day time.Time) (int, float64, error) {
+	return accrueInterest(db, day, nil)
+}
+
+// accrueInterest is AccrueInterest limited by starts, the first day each
+// account is due from. A nil starts accrues every account. Days before an
+// account was opened never accrue.
+func accrueInterest(db *gorm.DB, day time.Time, starts map[uint]time.Time) (int, float64, error) {
+	day = truncateDay(day)
+	var products []models.InterestProduct
+	if err := db.Where("active = ?", true).Find(&products).Error; err != nil {
+		return 0, 0, err
+	}
+	added, total := 0, 0.0
+	for _, product := range products {
+		var users []models.User
+		if err := db.Where("interest_product_id = ?", product.ID).Find(&users).Error; err != nil {
+			return added, total, err
+		}
+		for _, user := range users {
+			if user.OpenedAt != nil && day.Before(truncateDay(user.OpenedAt.In(day.Location()))) {
+				continue
+			}
+			if starts != nil {
+				if start, ok := starts[user.ID]; !ok || day.Before(start) {
+					continue
+				}
+			}
+			balance, err := closingBalance(db, user, day)
+			if err != nil {
+				return added, total, err
+			}
+			amount := math.Max(0, balance) * product.AnnualRate / 100 * dayFraction(product.DayCount, day)
+			accrual := models.InterestAccrual{
+				UserID:      user.ID,
+				AccrualDate: day,
+				ProductID:   product.ID,
+				Balance:     balance,
+				AnnualRate:  product.AnnualRate,
+				Amount:      math.Round(amount*1e6) / 1e6,
+			}
+			result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&accrual)
+			if result.Error != nil {
+				return added, total, result.Error
+			}
+			if result.RowsAffected > 0 {
+				added++
+				total += accrual.Amount
+			}
+		}
+	}
+	return added, total, nil
+}
+
+// PostInterest pays out the unposted accruals of one calendar month as interest
+// transactions from the house interest account. Posted accruals are marked, so
+// running it twice for the same month posts nothing the second time.
+func PostInterest(db *gorm.DB, month time.Time) (int, float64, error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	end := start.AddDate(0, 1, 0)
+	var userIDs []uint
+	if err := db.Model(&models.InterestAccrual{}).
+		Where("accrual_date >= ? AND accrual_date < ? AND posted_transaction_id IS NULL", start, end).
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return 0, 0, err
+	}
+	posted, total := 0, 0.0
+	for _, userID := range userIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			house, err := SystemAccount(tx, models.SystemAccountInterest)
+			if err != nil {
+				return err
+			}
+			user := models.User{ID: userID}
+			if err := LockUsers(tx, &user, &house); err != nil {
+				if errors.Is(err, ErrUserNotFound) {
+					// Deleted since accruing; deletion requires a zero balance, so nothing is owed
+					return nil
+				}
+				return err
+			}
+			// Re-read under the user lock so a concurrent run cannot post the same accruals
+			var accruals []models.InterestAccrual
+			if err := tx.Where("user_id = ? AND accrual_date >= ? AND accrual_date < ? AND posted_transaction_id IS NULL", userID, start, end).
+				Find(&accruals).Error; err != nil {
+				return err
+			}
+			var sum float64
+			ids := make([]uint, 0, len(accruals))
+			for _, a := range accruals {
+				sum += a.Amount
+				ids = append(ids, a.ID)
+			}
+			amount := roundMoney(sum)
+			if len(ids) == 0 {
+				return nil
+			}
+			if amount <= 0 {
+				// Nothing to pay, but mark zero accruals so they are not picked up again
+				return tx.Model(&models.InterestAccrual{}).Where("id IN ?", ids).Update("posted_transaction_id", 0).Error
+			}
+			txn, err := PostLedger(tx, &house, &user, amount, models.Transaction{
+				Kind: models.TransactionInterest,
+				Memo: fmt.Sprintf("Interest %s", start.Format("2006-01")),
+			})
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.InterestAccrual{}).Where("id IN ?", ids).Update("posted_transaction_id", txn.ID).Error; err != nil {
+				return err
+			}
+			posted++
+			total += amount
+			return nil
+		})
+		if err != nil {
+			return posted, total, err
+		}
+	}
+	return posted, total, nil
+}
+
+// RunInterest accrues every day from..to (inclusive) and posts every month that
+// ends inside the range. It can be rerun over the same range without double-posting.
+func RunInterest(db *gorm.DB, from, to time.Time) (InterestRunResult, error) {
+	return runInterest(db, from, to, nil)
+}
+
+// runInterest is RunInterest with accruals limited as in accrueInterest
+func runInterest(db *gorm.DB, from, to time.Time, starts map[uint]time.Time) (InterestRunResult, error) {
+	from, to = truncateDay(from), truncateDay(to)
+	result := InterestRunResult{From: from.Format("2006-01-02"), To: to.Format("2006-01-02"), MonthsComplete: []string{}}
+	if to.Before(from) {
+		return result, ErrInvalidDateRange
+	}
+	if !to.Before(truncateDay(time.Now())) {
+		return result, ErrDayNotEnded
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		added, amount, err := accrueInterest(db, day, starts)
+		if err != nil {
+			return result, err
+		}
+		result.AccrualsAdded += added
+		result.AccruedAmount += amount
+		// The last day of a month closes it for posting
+		if day.AddDate(0, 0, 1).Day() == 1 {
+			posted, amount, err := PostInterest(db, day)
+			if err != nil {
+				return result, err
+			}
+			result.PostingsMade += posted
+			result.PostedAmount += amount
+			result.MonthsComplete = append(result.MonthsComplete, day.Format("2006-01"))
+		}
+	}
+	result.AccruedAmount = math.Round(result.AccruedAmount*1e6) / 1e6
+	result.PostedAmount = roundMoney(result.PostedAmount)
+	return result, nil
+}
+
+// StartInterestJob accrues and posts interest up to the previous day, every
+// interval. Each account resumes from the day after its own last accrual, so
+// days missed while the server was down are caught up.
+func StartInterestJob(db *gorm.DB, interval time.Duration) {
+	runEvery("Interest", interval, func(now time.Time) (int, error) {
+		yesterday := truncateDay(now).AddDate(0, 0, -1)
+		starts, err := nextInterestDays(db, yesterday)
+		if err != nil {
+			return 0, err
+		}
+		from := yesterday.AddDate(0, 0, 1)
+		for _, start := range starts {
+			if start.Before(from) {
+				from = start
+			}
+		}
+		if from.After(yesterday) {
+			return 0, nil
+		}
+		result, err := runInterest(db, from, yesterday, starts)
+		return result.AccrualsAdded, err
+	})
+}
+
+// nextInterestDays returns, for every account on an active product, the day
+// after its latest accrual, or fallback when it has not accrued yet. No day is
+// before the account was opened.
+func nextInterestDays(db *gorm.DB, fallback time.Time) (map[uint]time.Time, error) {
+	var users []models.User
+	if err := db.Where("interest_product_id IN (?)", db.Model(&models.InterestProduct{}).Select("id").Where("active = ?", true)).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	starts := make(map[uint]time.Time, len(users))
+	for _, user := range users {
+		start := fallback
+		var last models.InterestAccrual
+		err := db.Where("user_id = ?", user.ID).Order("accrual_date DESC").First(&last).Error
+		if err == nil {
+			start = truncateDay(last.AccrualDate.In(fallback.Location())).AddDate(0, 0, 1)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if user.OpenedAt != nil {
+			if opened := truncateDay(user.OpenedAt.In(fallback.Location())); start.Before(opened) {
+				start = opened
+			}
+		}
+		starts[user.ID] = start
+	}
+	return starts, nil
+}
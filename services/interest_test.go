@@ -0,0 +1,111 @@
+package services_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"gorm.io/gorm"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// saver opens an account on a new product at 10% a year
+func saver(t *testing.T, db *gorm.DB, username, base string, credit float64, dayCount string, opened time.Time) models.User {
+	t.Helper()
+	product := models.InterestProduct{Name: dayCount, AnnualRate: 10, DayCount: dayCount, Active: true}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := testutil.CreateUser(t, db, username, base, credit)
+	if err := db.Model(&user).Updates(map[string]interface{}{"interest_product_id": product.ID, "opened_at": opened}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestAccrueInterest(t *testing.T) {
+	tests := []struct {
+		name     string
+		dayCount string
+		credit   float64
+		opened   time.Time
+		day      time.Time
+		added    int
+		want     float64
+	}{
+		{"ACT/365", models.DayCountActual365, 36500, date(2024, 1, 1), date(2024, 3, 5), 1, 10},
+		{"ACT/360", models.DayCountActual360, 36000, date(2024, 1, 1), date(2024, 3, 5), 1, 10},
+		{"30/360 skips the 31st", models.DayCount30360, 36000, date(2024, 1, 1), date(2024, 3, 31), 1, 0},
+		{"30/360 end of February", models.DayCount30360, 36000, date(2023, 1, 1), date(2023, 2, 28), 1, 30},
+		{"negative balance earns nothing", models.DayCountActual365, -500, date(2024, 1, 1), date(2024, 3, 5), 1, 0},
+		{"opening day accrues", models.DayCountActual365, 36500, date(2024, 3, 5).Add(15 * time.Hour), date(2024, 3, 5), 1, 10},
+		{"before opening", models.DayCountActual365, 36500, date(2024, 3, 6), date(2024, 3, 5), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			saver(t, db, "saver", "123456789", tt.credit, tt.dayCount, tt.opened)
+			added, amount, err := services.AccrueInterest(db, tt.day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if added != tt.added || math.Abs(amount-tt.want) > 1e-6 {
+				t.Errorf("AccrueInterest() = %d, %v, want %d, %v", added, amount, tt.added, tt.want)
+			}
+			// A second run adds nothing
+			if added, _, err := services.AccrueInterest(db, tt.day); err != nil || added != 0 {
+				t.Errorf("rerun added %d, err %v", added, err)
+			}
+		})
+	}
+}
+
+func TestInterestCatchUpIsPerAccount(t *testing.T) {
+	db := testutil.NewDB(t)
+	yesterday := date(2024, 3, 20)
+	behind := saver(t, db, "behind", "123456789", 1000, models.DayCountActual365, date(2024, 1, 1))
+	current := saver(t, db, "current", "234567891", 1000, models.DayCountActual365, date(2024, 1, 1))
+	fresh := saver(t, db, "fresh", "345678912", 1000, models.DayCountActual365, date(2024, 1, 1))
+	late := saver(t, db, "late", "456789123", 1000, models.DayCountActual365, date(2024, 3, 25))
+	for user, day := range map[uint]time.Time{behind.ID: date(2024, 3, 10), current.ID: date(2024, 3, 18)} {
+		if err := db.Create(&models.InterestAccrual{UserID: user, AccrualDate: day}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	starts, err := services.NextInterestDays(db, yesterday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint]time.Time{
+		behind.ID:  date(2024, 3, 11),
+		current.ID: date(2024, 3, 19),
+		fresh.ID:   yesterday,
+		late.ID:    date(2024, 3, 25),
+	}
+	for id, day := range want {
+		if !starts[id].Equal(day) {
+			t.Errorf("start for user %d = %v, want %v", id, starts[id], day)
+		}
+	}
+
+	if _, err := services.RunInterestFrom(db, date(2024, 3, 11), yesterday, starts); err != nil {
+		t.Fatal(err)
+	}
+	for id, days := range map[uint]int64{behind.ID: 11, current.ID: 3, fresh.ID: 1, late.ID: 0} {
+		var count int64
+		if err := db.Model(&models.InterestAccrual{}).Where("user_id = ?", id).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != days {
+			t.Errorf("user %d has %d accruals, want %d", id, count, days)
+		}
+	}
+}
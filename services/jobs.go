@@ -0,0 +1,33 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// runEvery calls job straight away and then every interval until the process exits.
+// job returns how many accounts it changed, which is logged when non-zero.
+func runEvery(name string, interval time.Duration, job func(now time.Time) (int, error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			changed, err := job(time.Now())
+			if err != nil {
+				log.Printf("%s job failed: %v", name, err)
+			} else if changed > 0 {
+				log.Printf("%s job updated %d accounts", name, changed)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// StartOverdraftJob runs FlagOverdrawnAccounts every interval until the process exits
+func StartOverdraftJob(db *gorm.DB, interval time.Duration) {
+	runEvery("Overdraft", interval, func(now time.Time) (int, error) {
+		return FlagOverdrawnAccounts(db, now)
+	})
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LockUsers re-reads users inside tx with row locks, always in ID order so two
+// concurrent postings between the same accounts cannot deadlock.
+func LockUsers(tx *gorm.DB, users ...*models.User) error {
+	sorted := append([]*models.User(nil), users...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	for _, user := range sorted {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(user, user.ID).Error; err != nil {
+			return lookupError(err)
+		}
+	}
+	return nil
+}
+
+// ErrSystemAccountDeleted is returned when a house account has been soft-deleted.
+// Postings stop until it is restored, which startup does automatically.
+var ErrSystemAccountDeleted = errors.New("system account is deleted")
+
+// SystemAccount loads a house account by its username
+func SystemAccount(db *gorm.DB, username string) (models.User, error) {
+	var user models.User
+	if err := db.Unscoped().Where("username = ? AND role = ?", username, models.RoleSystem).First(&user).Error; err != nil {
+		return models.User{}, lookupError(err)
+	}
+	if user.DeletedAt.Valid {
+		return models.User{}, fmt.Errorf("%w: %s", ErrSystemAccountDeleted, username)
+	}
+	return user, nil
+}
+
+// PostLedger moves amount from one locked user to another and records the line.
+// Callers run it inside a database transaction after LockUsers; txn carries the
+// kind and any parent or memo, and is returned with balances and ID filled in.
+func PostLedger(tx *gorm.DB, from, to *models.User, amount float64, txn models.Transaction) (models.Transaction, error) {
+	now := time.Now()
+	from.Credit -= amount
+	to.Credit += amount
+	TrackOverdrawn(from, now)
+	TrackOverdrawn(to, now)
+	if err := tx.Model(from).Select("credit", "overdrawn_since", "overdraft_flagged_at").Updates(from).Error; err != nil {
+		return models.Transaction{}, err
+	}
+	if err := tx.Model(to).Select("credit", "overdrawn_since", "overdraft_flagged_at").Updates(to).Error; err != nil {
+		return models.Transaction{}, err
+	}
+	txn.SenderID = from.ID
+	txn.SenderRemaining = from.Credit
+	txn.ReceiverID = to.ID
+	txn.ReceiverRemaining = to.Credit
+	txn.Amount = amount
+	if txn.Kind == "" {
+		txn.Kind = models.TransactionTransfer
+	}
+	txn.CreatedAt = now
+	if err := chainTransaction(tx, &txn); err != nil {
+		return models.Transaction{}, err
+	}
+	if err := tx.Create(&txn).Error; err != nil {
+		return models.Transaction{}, err
+	}
+	return txn, nil
+}
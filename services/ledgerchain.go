@@ -0,0 +1,199 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LedgerVerification is the outcome of checking one account's transaction chain
+type LedgerVerification struct {
+	UserID        uint   `json:"user_id"`
+	AccountNumber string `json:"account_number"`
+	Checked       int    `json:"checked"`
+	Valid         bool   `json:"valid"`
+	// HeadHash is the hash of the account's newest transaction
+	HeadHash string `json:"head_hash"`
+	// BrokenAt is the first transaction whose hash or link does not match
+	BrokenAt uint   `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// transactionHash is the SHA-256 of a transaction's contents and previous hashes.
+// The ID is left out as it is only known after insert; the links fix the order.
+func transactionHash(t models.Transaction) string {
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	parent := ""
+	if t.ParentID != nil {
+		parent = fmt.Sprint(*t.ParentID)
+	}
+	fields := []string{
+		t.SenderPrevHash, t.ReceiverPrevHash,
+		fmt.Sprint(t.SenderID), money(t.SenderRemaining),
+		fmt.Sprint(t.ReceiverID), money(t.ReceiverRemaining),
+		money(t.Amount), money(t.Fee), t.Kind, parent, t.Memo,
+		t.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// lastTransactionHash is the hash of the account's newest transaction, or "" for none
+func lastTransactionHash(tx *gorm.DB, userID uint) (string, error) {
+	var last models.Transaction
+	err := tx.Where("sender_id = ? OR receiver_id = ?", userID, userID).Order("id DESC").Limit(1).Find(&last).Error
+	return last.Hash, err
+}
+
+// chainTransaction links txn to the newest transaction of both its accounts,
+// hashes it and moves both accounts' chain heads to it. Callers hold the locks
+// on both users, so nothing can be posted in between, and create txn in the
+// same database transaction.
+func chainTransaction(tx *gorm.DB, txn *models.Transaction) error {
+	var err error
+	if txn.SenderPrevHash, err = lastTransactionHash(tx, txn.SenderID); err != nil {
+		return err
+	}
+	if txn.ReceiverPrevHash, err = lastTransactionHash(tx, txn.ReceiverID); err != nil {
+		return err
+	}
+	// Stored with millisecond precision, so hash what will be read back
+	txn.CreatedAt = txn.CreatedAt.Truncate(time.Millisecond)
+	txn.UpdatedAt = txn.CreatedAt
+	txn.Hash = transactionHash(*txn)
+	if err := advanceChainHead(tx, txn.SenderID, txn.Hash); err != nil {
+		return err
+	}
+	if txn.ReceiverID == txn.SenderID {
+		return nil
+	}
+	return advanceChainHead(tx, txn.ReceiverID, txn.Hash)
+}
+
+// advanceChainHead records hash as the newest link of the account's chain
+func advanceChainHead(tx *gorm.DB, userID uint, hash string) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LedgerChainHead{UserID: userID}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.LedgerChainHead{UserID: userID}).
+		Updates(map[string]interface{}{"hash": hash, "transactions": gorm.Expr("transactions + 1")}).Error
+}
+
+// SealTransactions hashes transactions written before chaining existed, oldest
+// first, linking them into their accounts' chains. Hashed rows are left alone.
+// Accounts whose chains predate stored heads get one from their current chain.
+func SealTransactions(db *gorm.DB) (int, error) {
+	var unsealed int64
+	if err := db.Model(&models.Transaction{}).Where("hash = '' OR hash IS NULL").Count(&unsealed).Error; err != nil {
+		return 0, err
+	}
+	if unsealed == 0 {
+		return 0, recordMissingChainHeads(db)
+	}
+	heads := map[uint]string{}
+	sealed := 0
+	var batch []models.Transaction
+	err := db.Order("id").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, t := range batch {
+			if t.Hash == "" {
+				t.SenderPrevHash, t.ReceiverPrevHash = heads[t.SenderID], heads[t.ReceiverID]
+				t.Hash = transactionHash(t)
+				if err := db.Model(&t).UpdateColumns(map[string]interface{}{
+					"hash":               t.Hash,
+					"sender_prev_hash":   t.SenderPrevHash,
+					"receiver_prev_hash": t.ReceiverPrevHash,
+				}).Error; err != nil {
+					return err
+				}
+				sealed++
+			}
+			heads[t.SenderID], heads[t.ReceiverID] = t.Hash, t.Hash
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return sealed, err
+	}
+	return sealed, recordMissingChainHeads(db)
+}
+
+// recordMissingChainHeads stores a head for every account that has transactions
+// but no head yet, taken from its newest transaction
+func recordMissingChainHeads(db *gorm.DB) error {
+	var userIDs []uint
+	if err := db.Model(&models.User{}).Unscoped().
+		Where("NOT EXISTS (SELECT 1 FROM ledger_chain_heads h WHERE h.user_id = users.id)").
+		Where("EXISTS (SELECT 1 FROM transactions t WHERE t.sender_id = users.id OR t.receiver_id = users.id)").
+		Order("id").Pluck("id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		var count int64
+		if err := db.Model(&models.Transaction{}).Where("sender_id = ? OR receiver_id = ?", userID, userID).Count(&count).Error; err != nil {
+			return err
+		}
+		hash, err := lastTransactionHash(db, userID)
+		if err != nil {
+			return err
+		}
+		head := models.LedgerChainHead{UserID: userID, Hash: hash, Transactions: count}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&head).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyLedgerChain recomputes every hash in one account's chain, oldest first,
+// checks each transaction links to the one before it and that the chain ends
+// at the account's stored head
+func VerifyLedgerChain(db *gorm.DB, userID uint) (LedgerVerification, error) {
+	result := LedgerVerification{UserID: userID, Valid: true}
+	var user models.User
+	if err := db.Unscoped().First(&user, userID).Error; err != nil {
+		return result, lookupError(err)
+	}
+	result.AccountNumber = user.AccountNumber
+	var txns []models.Transaction
+	if err := db.Where("sender_id = ? OR receiver_id = ?", userID, userID).Order("id").Find(&txns).Error; err != nil {
+		return result, err
+	}
+	prev := ""
+	for _, t := range txns {
+		result.Checked++
+		link := t.ReceiverPrevHash
+		if t.SenderID == userID {
+			link = t.SenderPrevHash
+		}
+		switch {
+		case link != prev:
+			result.Valid, result.BrokenAt, result.Reason = false, t.ID, "previous hash does not match the account's transaction before it"
+		case transactionHash(t) != t.Hash:
+			result.Valid, result.BrokenAt, result.Reason = false, t.ID, "transaction does not match its hash"
+		}
+		if !result.Valid {
+			return result, nil
+		}
+		prev = t.Hash
+	}
+	result.HeadHash = prev
+	var head models.LedgerChainHead
+	if err := db.Limit(1).Find(&head, "user_id = ?", userID).Error; err != nil {
+		return result, err
+	}
+	if head.Hash != prev || head.Transactions != int64(result.Checked) {
+		result.Valid, result.Reason = false, "chain head does not match the newest transaction; transactions may have been removed or rehashed"
+		if len(txns) > 0 {
+			result.BrokenAt = txns[len(txns)-1].ID
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package services_test
+
+import (
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+
+	"gorm.io/gorm"
+)
+
+// postTransfers moves amounts from one account to the other through the ledger
+func postTransfers(t *testing.T, db *gorm.DB, from, to models.User, amounts ...float64) []models.Transaction {
+	t.Helper()
+	var txns []models.Transaction
+	for _, amount := range amounts {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := services.LockUsers(tx, &from, &to); err != nil {
+				return err
+			}
+			txn, err := services.PostLedger(tx, &from, &to, amount, models.Transaction{Kind: models.TransactionTransfer})
+			txns = append(txns, txn)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("post %.2f: %v", amount, err)
+		}
+	}
+	return txns
+}
+
+func TestVerifyLedgerChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, db *gorm.DB, txns []models.Transaction)
+		valid  bool
+	}{
+		{"intact", func(*testing.T, *gorm.DB, []models.Transaction) {}, true},
+		{"edited row", func(t *testing.T, db *gorm.DB, txns []models.Transaction) {
+			db.Model(&txns[1]).UpdateColumn("amount", 999)
+		}, false},
+		{"newest rows removed", func(t *testing.T, db *gorm.DB, txns []models.Transaction) {
+			db.Delete(&models.Transaction{}, txns[2].ID)
+		}, false},
+		{"chain rehashed after an edit", func(t *testing.T, db *gorm.DB, txns []models.Transaction) {
+			db.Model(&txns[1]).UpdateColumn("amount", 999)
+			db.Model(&models.Transaction{}).Where("id >= ?", txns[1].ID).UpdateColumn("hash", "")
+			if _, err := services.SealTransactions(db); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			sender := testutil.CreateUser(t, db, "sender", "123456789", 500)
+			receiver := testutil.CreateUser(t, db, "receiver", "234567891", 0)
+			txns := postTransfers(t, db, sender, receiver, 10, 20, 30)
+			tt.tamper(t, db, txns)
+
+			for _, user := range []models.User{sender, receiver} {
+				result, err := services.VerifyLedgerChain(db, user.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Valid != tt.valid {
+					t.Errorf("%s: valid = %v, want %v (%s)", user.Username, result.Valid, tt.valid, result.Reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottleSettings tunes how failed logins slow down and lock out a key
+type LoginThrottleSettings struct {
+	// FreeAttempts failures are allowed before any delay
+	FreeAttempts int
+	// BaseDelay doubles with every failure after the free ones, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures lock the key for Lockout
+	LockoutAfter int
+	Lockout      time.Duration
+	// Failures older than Window are forgotten
+	Window time.Duration
+}
+
+// LoginThrottles holds the settings for usernames and client IPs. An IP gets
+// more room since many users can share one.
+var LoginThrottles = map[string]LoginThrottleSettings{
+	models.ThrottleUsername: {FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Minute, LockoutAfter: 10, Lockout: 30 * time.Minute, Window: time.Hour},
+	models.ThrottleIP:       {FreeAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Minute, LockoutAfter: 50, Lockout: 30 * time.Minute, Window: time.Hour},
+}
+
+// loginKey is one throttle row a login attempt counts against
+type loginKey struct {
+	Kind    string
+	Subject string
+}
+
+// loginKeys lists the throttles a login attempt counts against, always the
+// username before the IP so concurrent failures lock the rows in one order
+func loginKeys(username, ip string) []loginKey {
+	return []loginKey{
+		{Kind: models.ThrottleUsername, Subject: strings.ToLower(strings.TrimSpace(username))},
+		{Kind: models.ThrottleIP, Subject: ip},
+	}
+}
+
+// LoginBlockedUntil reports until when logins for username or from ip are held back
+func LoginBlockedUntil(db *gorm.DB, username, ip string, now time.Time) (time.Time, bool, error) {
+	var until time.Time
+	for _, key := range loginKeys(username, ip) {
+		var throttle models.LoginThrottle
+		err := db.Where("kind = ? AND subject = ? AND locked_until > ?", key.Kind, key.Subject, now).Limit(1).Find(&throttle).Error
+		if err != nil {
+			return until, false, err
+		}
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(until) {
+			until = *throttle.LockedUntil
+		}
+	}
+	return until, !until.IsZero(), nil
+}
+
+// loginDelay is how long a key is held back after its nth failure
+func loginDelay(settings LoginThrottleSettings, failures int) time.Duration {
+	if failures >= settings.LockoutAfter {
+		return settings.Lockout
+	}
+	if failures <= settings.FreeAttempts {
+		return 0
+	}
+	delay := settings.BaseDelay
+	for i := settings.FreeAttempts + 1; i < failures && delay < settings.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > settings.MaxDelay {
+		delay = settings.MaxDelay
+	}
+	return delay
+}
+
+// RecordLoginFailure counts a failed login against both the username and the IP
+func RecordLoginFailure(db *gorm.DB, username, ip string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range loginKeys(username, ip) {
+			settings := LoginThrottles[key.Kind]
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.LoginThrottle{Kind: key.Kind, Subject: key.Subject}).Error; err != nil {
+				return err
+			}
+			var throttle models.LoginThrottle
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("kind = ? AND subject = ?", key.Kind, key.Subject).First(&throttle).Error; err != nil {
+				return err
+			}
+			failures := throttle.Failures
+			if throttle.LastFailureAt != nil && now.Sub(*throttle.LastFailureAt) > settings.Window {
+				failures = 0
+			}
+			failures++
+			updates := map[string]interface{}{"failures": failures, "last_failure_at": now}
+			if delay := loginDelay(settings, failures); delay > 0 {
+				updates["locked_until"] = now.Add(delay)
+			}
+			if err := tx.Model(&throttle).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordLoginSuccess clears the username's failures. The IP keeps its count so
+// one valid account cannot be used to reset an attacker's address.
+func RecordLoginSuccess(db *gorm.DB, username string) error {
+	key := loginKeys(username, "")[0]
+	return db.Where("kind = ? AND subject = ?", key.Kind, key.Subject).
+		Delete(&models.LoginThrottle{}).Error
+}
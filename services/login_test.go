@@ -0,0 +1,77 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/services"
+)
+
+func TestLoginBackoffAndLockout(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{"within free attempts", 3, 0},
+		{"first delay", 4, time.Second},
+		{"delay doubles", 6, 4 * time.Second},
+		{"one short of lockout", 9, 32 * time.Second},
+		{"locked out", 10, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.NewDB(t)
+			for i := 0; i < tt.failures; i++ {
+				if err := services.RecordLoginFailure(db, "Alice", "10.0.0.1", now); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// The username is matched however it is typed, from any address
+			until, blocked, err := services.LoginBlockedUntil(db, " alice ", "10.0.0.2", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if blocked != (tt.want > 0) || (blocked && until.Sub(now) != tt.want) {
+				t.Errorf("blocked %v for %v, want %v", blocked, until.Sub(now), tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginFailuresExpireAndReset(t *testing.T) {
+	db := testutil.NewDB(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if err := services.RecordLoginFailure(db, "alice", "10.0.0.1", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Failures older than the window are forgotten
+	later := now.Add(2 * time.Hour)
+	if err := services.RecordLoginFailure(db, "alice", "10.0.0.1", later); err != nil {
+		t.Fatal(err)
+	}
+	if _, blocked, err := services.LoginBlockedUntil(db, "alice", "10.0.0.3", later); err != nil || blocked {
+		t.Errorf("after window: blocked %v, err %v, want the count to restart", blocked, err)
+	}
+
+	// A success clears the username but not the IP
+	for i := 0; i < 60; i++ {
+		if err := services.RecordLoginFailure(db, "mallory", "10.0.0.9", later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := services.RecordLoginSuccess(db, "mallory"); err != nil {
+		t.Fatal(err)
+	}
+	if _, blocked, _ := services.LoginBlockedUntil(db, "mallory", "10.0.0.3", later); blocked {
+		t.Error("username still blocked after a successful login")
+	}
+	if _, blocked, _ := services.LoginBlockedUntil(db, "bob", "10.0.0.9", later); !blocked {
+		t.Error("IP lockout cleared by a successful login")
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+	"gotestbackend/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MFAIssuer is the name authenticator apps show next to the account
+var MFAIssuer = "gotestbackend"
+
+// RecoveryCodeCount is how many recovery codes are issued on enrolment
+var RecoveryCodeCount = 10
+
+// mfaSkew is how many 30-second steps of clock drift are tolerated either way
+const mfaSkew = 1
+
+var (
+	// ErrMFAAlreadyEnabled is returned when enrolling a user who already confirmed an authenticator
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	// ErrMFANotEnrolled is returned when there is no enrolment to confirm or verify against
+	ErrMFANotEnrolled = errors.New("two-factor authentication not enrolled")
+	// ErrInvalidMFACode is returned for a wrong, reused or expired code
+	ErrInvalidMFACode = errors.New("invalid authentication code")
+)
+
+// MFAEnabled reports whether the user has a confirmed authenticator
+func MFAEnabled(db *gorm.DB, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.MFAEnrollment{}).Where("user_id = ? AND confirmed_at IS NOT NULL", userID).Count(&count).Error
+	return count > 0, err
+}
+
+// BeginMFAEnrollment issues a new TOTP secret for the user. It does not protect
+// login until ConfirmMFAEnrollment sees a valid code. Starting again replaces
+// an unconfirmed secret.
+func BeginMFAEnrollment(db *gorm.DB, user models.User) (secret, uri string, err error) {
+	enabled, err := MFAEnabled(db, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+	secret, err = utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	enrollment := models.MFAEnrollment{UserID: user.ID, Secret: secret}
+	err = db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"secret": secret, "confirmed_at": nil, "last_used_step": 0, "updated_at": time.Now()}),
+	}).Create(&enrollment).Error
+	if err != nil {
+		return "", "", err
+	}
+	return secret, utils.TOTPProvisioningURI(MFAIssuer, user.Username, secret), nil
+}
+
+// ConfirmMFAEnrollment turns on two-factor authentication once the user proves
+// their authenticator works, and returns fresh recovery codes. The codes are
+// only ever shown here.
+func ConfirmMFAEnrollment(db *gorm.DB, userID uint, code string, now time.Time) ([]string, error) {
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var enrollment models.MFAEnrollment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&enrollment, "user_id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMFANotEnrolled
+			}
+			return err
+		}
+		if enrollment.ConfirmedAt != nil {
+			return ErrMFAAlreadyEnabled
+		}
+		step, ok := utils.MatchTOTP(enrollment.Secret, code, now, mfaSkew)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		if err := tx.Model(&enrollment).Updates(map[string]interface{}{"confirmed_at": now, "last_used_step": step}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// replaceRecoveryCodes drops a user's recovery codes and issues a new set
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(raw)
+		code = code[:5] + "-" + code[5:]
+		if err := tx.Create(&models.MFARecoveryCode{UserID: userID, Hash: hashRecoveryCode(code)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode normalises a recovery code and hashes it
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
+
+// VerifyMFACode accepts either the current TOTP code or an unused recovery code.
+// A TOTP code is accepted once; a recovery code is used up.
+func VerifyMFACode(db *gorm.DB, userID uint, code string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var enrollment models.MFAEnrollment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("confirmed_at IS NOT NULL").First(&enrollment, "user_id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMFANotEnrolled
+			}
+			return err
+		}
+		code = strings.TrimSpace(code)
+		if step, ok := utils.MatchTOTP(enrollment.Secret, code, now, mfaSkew); ok {
+			if step <= enrollment.LastUsedStep {
+				return ErrInvalidMFACode
+			}
+			return tx.Model(&enrollment).Update("last_used_step", step).Error
+		}
+		result := tx.Model(&models.MFARecoveryCode{}).
+			Where("user_id = ? AND hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidMFACode
+		}
+		return nil
+	})
+}
@@ -0,0 +1,57 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/services"
+	"gotestbackend/utils"
+)
+
+func TestVerifyMFACodeRejectsReplay(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.CreateUser(t, db, "alice", "123456789", 0)
+	secret, _, err := services.BeginMFAEnrollment(db, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	step := utils.TOTPStep(now)
+	code := func(step int64) string {
+		c, err := utils.TOTPCode(secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	if err := services.VerifyMFACode(db, user.ID, code(step), now); !errors.Is(err, services.ErrMFANotEnrolled) {
+		t.Fatalf("before confirming: err = %v, want ErrMFANotEnrolled", err)
+	}
+	recovery, err := services.ConfirmMFAEnrollment(db, user.ID, code(step), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		code string
+		at   time.Time
+		want error
+	}{
+		{"code used to confirm", code(step), now, services.ErrInvalidMFACode},
+		{"next code", code(step + 1), now.Add(utils.TOTPPeriod * time.Second), nil},
+		{"same code again", code(step + 1), now.Add(utils.TOTPPeriod * time.Second), services.ErrInvalidMFACode},
+		{"older code within skew", code(step), now.Add(utils.TOTPPeriod * time.Second), services.ErrInvalidMFACode},
+		{"wrong code", "000000", now.Add(2 * utils.TOTPPeriod * time.Second), services.ErrInvalidMFACode},
+		{"recovery code", recovery[0], now, nil},
+		{"recovery code reused", recovery[0], now, services.ErrInvalidMFACode},
+		{"recovery code typed differently", " " + recovery[1][:5] + recovery[1][6:] + " ", now, nil},
+	}
+	for _, tt := range tests {
+		if err := services.VerifyMFACode(db, user.ID, tt.code, tt.at); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Notification is a message for one user
+type Notification struct {
+	UserID   uint
+	Username string
+	// Channel is models.ContactEmail or models.ContactPhone; To is the address on
+	// that channel. Both are empty when the user has no verified contact yet.
+	Channel string
+	To      string
+	Subject string
+	Body    string
+}
+
+// recipient names who a notification is for in the stand-in notifiers
+func (n Notification) recipient() string {
+	if n.To != "" {
+		return fmt.Sprintf("%s <%s:%s>", n.Username, n.Channel, n.To)
+	}
+	return n.Username
+}
+
+// Notifier delivers messages to users. Set Notify to a real mail or SMS gateway in production.
+type Notifier interface {
+	Send(n Notification) error
+}
+
+// LogNotifier writes notifications to the application log
+type LogNotifier struct{}
+
+// Send logs the notification
+func (LogNotifier) Send(n Notification) error {
+	log.Printf("Notify %s (user %d): %s\n%s", n.recipient(), n.UserID, n.Subject, n.Body)
+	return nil
+}
+
+// FileNotifier writes each notification to its own file in Dir, like a local outbox
+type FileNotifier struct {
+	Dir string
+}
+
+// Send writes the notification to a new file
+func (f FileNotifier) Send(n Notification) error {
+	if err := os.MkdirAll(f.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-user%d.txt", time.Now().Format("20060102T150405.000000000"), n.UserID)
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", n.recipient(), n.Subject, n.Body)
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(content), 0o600)
+}
+
+// Notify is the notifier used for password resets, verification codes and other messages to users
+var Notify Notifier = FileNotifier{Dir: "outbox"}
@@ -0,0 +1,480 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gotestbackend/models"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// OIDCProvider is one OpenID Connect issuer users can log in with
+type OIDCProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// AllowSignup creates a user on first login with an unknown identity
+	AllowSignup bool
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+// OIDCProviders holds the configured providers by name. main fills it from
+// the environment with LoadOIDCProviders.
+var OIDCProviders = map[string]*OIDCProvider{}
+
+// LoadOIDCProviders reads provider settings through getenv, normally os.Getenv.
+// OIDC_PROVIDERS lists provider names; each name's settings use its upper-case
+// form with dashes as underscores:
+//
+//	OIDC_PROVIDERS=corp
+//	OIDC_CORP_ISSUER=https://sso.example.com
+//	OIDC_CORP_CLIENT_ID=gotestbackend
+//	OIDC_CORP_CLIENT_SECRET=...
+//	OIDC_CORP_REDIRECT_URL=https://bank.example.com/api/auth/oidc/corp/callback
+//	OIDC_CORP_ALLOW_SIGNUP=1
+func LoadOIDCProviders(getenv func(string) string) (map[string]*OIDCProvider, error) {
+	providers := map[string]*OIDCProvider{}
+	for _, name := range strings.Split(getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := &OIDCProvider{
+			Issuer:       getenv(prefix + "ISSUER"),
+			ClientID:     getenv(prefix + "CLIENT_ID"),
+			ClientSecret: getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  getenv(prefix + "REDIRECT_URL"),
+			AllowSignup:  getenv(prefix+"ALLOW_SIGNUP") == "1",
+		}
+		for _, setting := range []string{"ISSUER", "CLIENT_ID", "REDIRECT_URL"} {
+			if getenv(prefix+setting) == "" {
+				return nil, fmt.Errorf("OIDC provider %s: %s%s is required", name, prefix, setting)
+			}
+		}
+		if _, err := url.ParseRequestURI(provider.Issuer); err != nil {
+			return nil, fmt.Errorf("OIDC provider %s: invalid issuer: %v", name, err)
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// OIDCStateTTL is how long a user has to finish logging in at the provider
+var OIDCStateTTL = 10 * time.Minute
+
+// oidcHTTPClient talks to providers
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	// ErrUnknownOIDCProvider is returned for a provider name that is not configured
+	ErrUnknownOIDCProvider = errors.New("unknown OIDC provider")
+	// ErrOIDCState is returned for an unknown, used or expired state
+	ErrOIDCState = errors.New("invalid or expired OIDC state")
+	// ErrOIDCExchange is returned when the provider rejects the code or returns a bad ID token
+	ErrOIDCExchange = errors.New("OIDC login failed")
+	// ErrOIDCSignupDisabled is returned when an unknown identity logs in and the provider does not allow signup
+	ErrOIDCSignupDisabled = errors.New("no user is linked to this identity")
+	// ErrIdentityLinked is returned when the identity already belongs to another user
+	ErrIdentityLinked = errors.New("identity is already linked to another user")
+	// ErrIdentityNotFound is returned when the user has no such linked identity
+	ErrIdentityNotFound = errors.New("identity not found")
+)
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCClaims are the ID token claims we use
+type OIDCClaims struct {
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	Audience          oidcAudience `json:"aud"`
+	ExpiresAt         int64        `json:"exp"`
+	IssuedAt          int64        `json:"iat"`
+	Nonce             string       `json:"nonce"`
+	Email             string       `json:"email"`
+	EmailVerified     bool         `json:"email_verified"`
+	PreferredUsername string       `json:"preferred_username"`
+	GivenName         string       `json:"given_name"`
+	FamilyName        string       `json:"family_name"`
+}
+
+// Valid checks expiry; issuer, audience and nonce are checked by verifyIDToken
+func (c OIDCClaims) Valid() error {
+	if time.Now().Unix() >= c.ExpiresAt {
+		return errors.New("ID token expired")
+	}
+	return nil
+}
+
+// oidcAudience accepts "aud" as either a string or a list
+type oidcAudience []string
+
+func (a *oidcAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = oidcAudience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// randomToken returns n random bytes, base64url-encoded
+func randomToken(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// getJSON fetches a provider document
+func getJSON(endpoint string, into interface{}) error {
+	resp, err := oidcHTTPClient.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
+
+// discover loads and caches the provider's configuration document
+func (p *OIDCProvider) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var doc oidcDiscovery
+	if err := getJSON(strings.TrimRight(p.Issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, err
+	}
+	if doc.Issuer != p.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", doc.Issuer, p.Issuer)
+	}
+	p.discovery = &doc
+	return p.discovery, nil
+}
+
+// publicKey finds the signing key by ID, refetching the key set once when the
+// ID is new so providers can rotate keys
+func (p *OIDCProvider) publicKey(kid string) (*rsa.PublicKey, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(doc.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	p.keys = map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		p.keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no signing key %q", kid)
+	}
+	return key, nil
+}
+
+// verifyIDToken checks the ID token's signature, issuer, audience, expiry and nonce
+func (p *OIDCProvider) verifyIDToken(raw, nonce string) (*OIDCClaims, error) {
+	claims := &OIDCClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.Issuer != p.Issuer {
+		return nil, errors.New("ID token issuer mismatch")
+	}
+	audienceOK := false
+	for _, aud := range claims.Audience {
+		if aud == p.ClientID {
+			audienceOK = true
+		}
+	}
+	if !audienceOK {
+		return nil, errors.New("ID token audience mismatch")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("ID token nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+	return claims, nil
+}
+
+// exchangeCode trades the authorization code and PKCE verifier for a verified ID token
+func (p *OIDCProvider) exchangeCode(code, verifier, nonce string) (*OIDCClaims, error) {
+	doc, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	resp, err := oidcHTTPClient.PostForm(doc.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+	return p.verifyIDToken(tokens.IDToken, nonce)
+}
+
+// StartOIDCLogin records a new authorization request and returns the URL to
+// send the browser to. linkUserID is set when a logged-in user links an identity.
+func StartOIDCLogin(db *gorm.DB, providerName string, linkUserID *uint, deviceLabel string) (string, error) {
+	provider, ok := OIDCProviders[providerName]
+	if !ok {
+		return "", ErrUnknownOIDCProvider
+	}
+	doc, err := provider.discover()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrOIDCExchange, err)
+	}
+	state, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken(48)
+	if err != nil {
+		return "", err
+	}
+	if err := db.Create(&models.OIDCLoginState{
+		State:        state,
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		LinkUserID:   linkUserID,
+		DeviceLabel:  deviceLabel,
+		ExpiresAt:    time.Now().Add(OIDCStateTTL),
+	}).Error; err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.ClientID},
+		"redirect_uri":          {provider.RedirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// OIDCResult is the outcome of a provider callback
+type OIDCResult struct {
+	User     models.User
+	Identity models.ExternalIdentity
+	// Linked is set when the callback finished linking rather than logging in
+	Linked      bool
+	DeviceLabel string
+}
+
+// FinishOIDCLogin handles the provider callback: it uses up the state, exchanges
+// the code and either links the identity or resolves the user to log in,
+// creating one if the provider allows signup.
+func FinishOIDCLogin(db *gorm.DB, providerName, state, code string) (OIDCResult, error) {
+	var result OIDCResult
+	provider, ok := OIDCProviders[providerName]
+	if !ok {
+		return result, ErrUnknownOIDCProvider
+	}
+	var pending models.OIDCLoginState
+	err := db.Where("state = ? AND provider = ?", state, providerName).First(&pending).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, ErrOIDCState
+	}
+	if err != nil {
+		return result, err
+	}
+	// A state works once, whatever happens next
+	deleted := db.Where("state = ?", state).Delete(&models.OIDCLoginState{})
+	if deleted.Error != nil {
+		return result, deleted.Error
+	}
+	if deleted.RowsAffected == 0 || time.Now().After(pending.ExpiresAt) {
+		return result, ErrOIDCState
+	}
+	claims, err := provider.exchangeCode(code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", ErrOIDCExchange, err)
+	}
+	result.DeviceLabel = pending.DeviceLabel
+	now := time.Now()
+
+	var identity models.ExternalIdentity
+	err = db.Where("provider = ? AND subject = ?", providerName, claims.Subject).First(&identity).Error
+	found := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, err
+	}
+
+	if pending.LinkUserID != nil {
+		if found && identity.UserID != *pending.LinkUserID {
+			return result, ErrIdentityLinked
+		}
+		if !found {
+			identity = models.ExternalIdentity{UserID: *pending.LinkUserID, Provider: providerName, Subject: claims.Subject, Email: claims.Email}
+			if err := db.Create(&identity).Error; err != nil {
+				return result, err
+			}
+		}
+		result.User, err = GetUserByID(db, identity.UserID)
+		result.Identity, result.Linked = identity, true
+		return result, err
+	}
+
+	if !found {
+		if !provider.AllowSignup {
+			return result, ErrOIDCSignupDisabled
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// The random password is never shown; the user can set one with a password reset
+			secret, err := randomToken(32)
+			if err != nil {
+				return err
+			}
+			hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			user := models.User{
+				Username:  oidcUsername(tx, providerName, claims),
+				Password:  string(hash),
+				FirstName: claims.GivenName,
+				LastName:  claims.FamilyName,
+			}
+			if err := OpenCustomerAccount(tx, &user); err != nil {
+				return err
+			}
+			identity = models.ExternalIdentity{UserID: user.ID, Provider: providerName, Subject: claims.Subject, Email: claims.Email}
+			return tx.Create(&identity).Error
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+	db.Model(&identity).Update("last_login_at", now)
+	identity.LastLoginAt = &now
+	result.User, err = GetUserByID(db, identity.UserID)
+	result.Identity = identity
+	return result, err
+}
+
+// oidcUsername picks a free username for a user created from an identity
+func oidcUsername(db *gorm.DB, providerName string, claims *OIDCClaims) string {
+	base := claims.PreferredUsername
+	if base == "" && claims.Email != "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	if base == "" {
+		base = providerName + "-" + claims.Subject
+	}
+	candidate := base
+	for i := 2; ; i++ {
+		var count int64
+		db.Unscoped().Model(&models.User{}).Where("username = ?", candidate).Count(&count)
+		if count == 0 {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+// UnlinkIdentity removes one of the user's linked identities
+func UnlinkIdentity(db *gorm.DB, userID, identityID uint) error {
+	result := db.Where("id = ? AND user_id = ?", identityID, userID).Delete(&models.ExternalIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/services"
+	"gotestbackend/services/oidcmock"
+)
+
+func TestLoadOIDCProviders(t *testing.T) {
+	env := map[string]string{
+		"OIDC_PROVIDERS":                "corp, partner-sso",
+		"OIDC_CORP_ISSUER":              "https://sso.example.com",
+		"OIDC_CORP_CLIENT_ID":           "bank",
+		"OIDC_CORP_CLIENT_SECRET":       "secret",
+		"OIDC_CORP_REDIRECT_URL":        "https://bank.example.com/callback",
+		"OIDC_CORP_ALLOW_SIGNUP":        "1",
+		"OIDC_PARTNER_SSO_ISSUER":       "https://partner.example.com",
+		"OIDC_PARTNER_SSO_CLIENT_ID":    "bank",
+		"OIDC_PARTNER_SSO_REDIRECT_URL": "https://bank.example.com/partner",
+	}
+	providers, err := services.LoadOIDCProviders(func(key string) string { return env[key] })
+	if err != nil {
+		t.Fatalf("LoadOIDCProviders: %v", err)
+	}
+	corp, ok := providers["corp"]
+	if !ok || corp.Issuer != "https://sso.example.com" || corp.ClientSecret != "secret" || !corp.AllowSignup {
+		t.Errorf("corp = %+v", corp)
+	}
+	partner, ok := providers["partner-sso"]
+	if !ok || partner.ClientID != "bank" || partner.AllowSignup {
+		t.Errorf("partner-sso = %+v", partner)
+	}
+
+	delete(env, "OIDC_CORP_CLIENT_ID")
+	if _, err := services.LoadOIDCProviders(func(key string) string { return env[key] }); err == nil {
+		t.Error("missing client ID accepted")
+	}
+	if providers, err := services.LoadOIDCProviders(func(string) string { return "" }); err != nil || len(providers) != 0 {
+		t.Errorf("no providers configured: got %d, %v", len(providers), err)
+	}
+}
+
+// useMockIssuer starts a mock issuer and registers it as provider "mock"
+func useMockIssuer(t *testing.T, allowSignup bool) *oidcmock.Issuer {
+	t.Helper()
+	issuer, err := oidcmock.NewIssuer("bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := services.OIDCProviders
+	services.OIDCProviders = map[string]*services.OIDCProvider{"mock": {
+		Issuer:      issuer.URL,
+		ClientID:    "bank",
+		RedirectURL: "http://localhost/api/auth/oidc/mock/callback",
+		AllowSignup: allowSignup,
+	}}
+	t.Cleanup(func() {
+		services.OIDCProviders = previous
+		issuer.Close()
+	})
+	return issuer
+}
+
+// oidcLogin runs the browser side of a login and returns the callback's state and code
+func oidcLogin(t *testing.T, issuer *oidcmock.Issuer, authURL string) (string, string) {
+	t.Helper()
+	callback, err := issuer.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return callback.Query().Get("state"), callback.Query().Get("code")
+}
+
+func TestOIDCLoginCreatesThenFindsUser(t *testing.T) {
+	db := testutil.NewDB(t)
+	issuer := useMockIssuer(t, true)
+	issuer.SignInAs(oidcmock.Identity{Subject: "sub-1", Email: "ann@example.com", PreferredUsername: "ann", GivenName: "Ann", FamilyName: "Lee"})
+
+	authURL, err := services.StartOIDCLogin(db, "mock", nil, "laptop")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	state, code := oidcLogin(t, issuer, authURL)
+	first, err := services.FinishOIDCLogin(db, "mock", state, code)
+	if err != nil {
+		t.Fatalf("FinishOIDCLogin: %v", err)
+	}
+	if first.User.Username != "ann" || first.User.FirstName != "Ann" || first.DeviceLabel != "laptop" {
+		t.Errorf("user = %+v, device %q", first.User, first.DeviceLabel)
+	}
+
+	// The state is single-use
+	if _, err := services.FinishOIDCLogin(db, "mock", state, code); !errors.Is(err, services.ErrOIDCState) {
+		t.Errorf("reused state: err = %v, want ErrOIDCState", err)
+	}
+
+	authURL, err = services.StartOIDCLogin(db, "mock", nil, "")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	state, code = oidcLogin(t, issuer, authURL)
+	second, err := services.FinishOIDCLogin(db, "mock", state, code)
+	if err != nil {
+		t.Fatalf("second FinishOIDCLogin: %v", err)
+	}
+	if second.User.ID != first.User.ID {
+		t.Errorf("second login user = %d, want %d", second.User.ID, first.User.ID)
+	}
+}
+
+func TestOIDCLoginWithoutSignupRejectsUnknownIdentity(t *testing.T) {
+	db := testutil.NewDB(t)
+	issuer := useMockIssuer(t, false)
+	issuer.SignInAs(oidcmock.Identity{Subject: "sub-2", PreferredUsername: "bob"})
+
+	authURL, err := services.StartOIDCLogin(db, "mock", nil, "")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	state, code := oidcLogin(t, issuer, authURL)
+	if _, err := services.FinishOIDCLogin(db, "mock", state, code); !errors.Is(err, services.ErrOIDCSignupDisabled) {
+		t.Fatalf("err = %v, want ErrOIDCSignupDisabled", err)
+	}
+}
+
+func TestOIDCLinkAttachesIdentityToUser(t *testing.T) {
+	db := testutil.NewDB(t)
+	issuer := useMockIssuer(t, false)
+	user := testutil.CreateUser(t, db, "carol", "123456789", 0)
+	issuer.SignInAs(oidcmock.Identity{Subject: "sub-3", Email: "carol@example.com"})
+
+	authURL, err := services.StartOIDCLogin(db, "mock", &user.ID, "")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	state, code := oidcLogin(t, issuer, authURL)
+	result, err := services.FinishOIDCLogin(db, "mock", state, code)
+	if err != nil {
+		t.Fatalf("FinishOIDCLogin: %v", err)
+	}
+	if !result.Linked || result.User.ID != user.ID || result.Identity.Subject != "sub-3" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestOIDCUnknownProvider(t *testing.T) {
+	db := testutil.NewDB(t)
+	if _, err := services.StartOIDCLogin(db, "nope", nil, ""); !errors.Is(err, services.ErrUnknownOIDCProvider) {
+		t.Fatalf("err = %v, want ErrUnknownOIDCProvider", err)
+	}
+}
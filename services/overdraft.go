@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OverdraftGraceDays is how long an account may stay overdrawn before the
+// nightly job flags it. Set from OVERDRAFT_GRACE_DAYS at startup.
+var OverdraftGraceDays = 30
+
+// ErrInvalidOverdraftLimit is returned for a negative overdraft limit
+var ErrInvalidOverdraftLimit = errors.New("overdraft limit must not be negative")
+
+// AvailableBalance is what a user can still spend, including their overdraft
+// and less anything held for pending withdrawals
+func AvailableBalance(user models.User) float64 {
+	return user.Credit + user.OverdraftLimit - user.HeldAmount
+}
+
+// OverdraftUsed is how far below zero the user's credit is
+func OverdraftUsed(user models.User) float64 {
+	return math.Max(0, -user.Credit)
+}
+
+// OverdraftAvailable is how much of the overdraft limit is still unused
+func OverdraftAvailable(user models.User) float64 {
+	return math.Max(0, user.OverdraftLimit-OverdraftUsed(user))
+}
+
+// TrackOverdrawn starts or clears the overdrawn clock after Credit has changed
+func TrackOverdrawn(user *models.User, now time.Time) {
+	if user.Credit < 0 {
+		if user.OverdrawnSince == nil {
+			user.OverdrawnSince = &now
+		}
+		return
+	}
+	user.OverdrawnSince = nil
+	user.OverdraftFlaggedAt = nil
+}
+
+// SetOverdraftLimit changes a user's overdraft limit and records who changed it and why
+func SetOverdraftLimit(db *gorm.DB, userID uint, limit float64, reason string, changedBy uint) (models.User, error) {
+	if limit < 0 {
+		return models.User{}, ErrInvalidOverdraftLimit
+	}
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return lookupError(err)
+		}
+		change := models.OverdraftLimitChange{
+			UserID:    user.ID,
+			OldLimit:  user.OverdraftLimit,
+			NewLimit:  limit,
+			Reason:    reason,
+			ChangedBy: changedBy,
+		}
+		if err := tx.Model(&user).Update("overdraft_limit", limit).Error; err != nil {
+			return err
+		}
+		return tx.Create(&change).Error
+	})
+	return user, err
+}
+
+// FlagOverdrawnAccounts flags accounts that have been overdrawn for longer than OverdraftGraceDays
+func FlagOverdrawnAccounts(db *gorm.DB, now time.Time) (int, error) {
+	// Accounts that went negative without passing through Transfer still need a start date
+	if err := db.Model(&models.User{}).
+		Where("credit < 0 AND overdrawn_since IS NULL AND role <> ?", models.RoleSystem).
+		Update("overdrawn_since", now).Error; err != nil {
+		return 0, err
+	}
+	cutoff := now.AddDate(0, 0, -OverdraftGraceDays)
+	result := db.Model(&models.User{}).
+		Where("credit < 0 AND overdrawn_since < ? AND overdraft_flagged_at IS NULL AND role <> ?", cutoff, models.RoleSystem).
+		Update("overdraft_flagged_at", now)
+	return int(result.RowsAffected), result.Error
+}
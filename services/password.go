@@ -0,0 +1,239 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"gotestbackend/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PasswordPolicySettings is what a password must satisfy
+type PasswordPolicySettings struct {
+	MinLength int
+	// MaxLength is capped by bcrypt, which ignores anything past 72 bytes
+	MaxLength int
+	// MinClasses is how many of lower case, upper case, digits and symbols must appear
+	MinClasses int
+}
+
+// PasswordPolicy is checked on register, on every password change and on
+// reset. Set from the PASSWORD_* variables at startup.
+var PasswordPolicy = PasswordPolicySettings{
+	MinLength:  10,
+	MaxLength:  72,
+	MinClasses: 3,
+}
+
+// ErrWeakPassword is returned when a password breaks the policy
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]bool {
+	set := map[string]bool{}
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			set[strings.ToLower(line)] = true
+		}
+	}
+	return set
+}()
+
+// ValidatePassword checks password against PasswordPolicy, the common-password
+// blocklist and the user's own username and names
+func ValidatePassword(password string, user models.User) error {
+	policy := PasswordPolicy
+	if len([]rune(password)) < policy.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, policy.MinLength)
+	}
+	if len(password) > policy.MaxLength {
+		return fmt.Errorf("%w: must be at most %d bytes", ErrWeakPassword, policy.MaxLength)
+	}
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < policy.MinClasses {
+		return fmt.Errorf("%w: must mix at least %d of lower case, upper case, digits and symbols", ErrWeakPassword, policy.MinClasses)
+	}
+	lowered := strings.ToLower(password)
+	if commonPasswords[lowered] {
+		return fmt.Errorf("%w: too common", ErrWeakPassword)
+	}
+	for _, part := range []string{user.Username, user.FirstName, user.LastName} {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if len(part) >= 3 && strings.Contains(lowered, part) {
+			return fmt.Errorf("%w: must not contain your username or name", ErrWeakPassword)
+		}
+	}
+	return nil
+}
+
+// HashPassword checks password against the policy and returns its bcrypt hash
+func HashPassword(password string, user models.User) (string, error) {
+	if err := ValidatePassword(password, user); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// SetPassword stores a new password for the user, records when it changed and
+// signs out every session
+func SetPassword(db *gorm.DB, user *models.User, password string) error {
+	hash, err := HashPassword(password, *user)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := db.Model(user).Updates(map[string]interface{}{"password": hash, "password_changed_at": now, "password_reset_required": false}).Error; err != nil {
+		return err
+	}
+	if _, err := RevokeSessions(db, user.ID, 0); err != nil {
+		return err
+	}
+	user.Password = hash
+	user.PasswordChangedAt = &now
+	user.PasswordResetRequired = false
+	return nil
+}
+
+// PasswordResetTTL is how long a reset token can be used
+var PasswordResetTTL = 30 * time.Minute
+
+// ErrInvalidResetToken is returned for an unknown, used or expired reset token
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// hashToken hashes a random token for storage. The tokens are long and random,
+// so SHA-256 is enough and lets them be looked up.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssuePasswordResetToken creates a reset token for the user and returns it.
+// Earlier unused tokens stop working.
+func IssuePasswordResetToken(db *gorm.DB, userID uint, now time.Time) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Only the newest token works
+		if err := tx.Where("user_id = ? AND used_at IS NULL", userID).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    userID,
+			Hash:      hashToken(token),
+			ExpiresAt: now.Add(PasswordResetTTL),
+		}).Error
+	})
+	return token, err
+}
+
+// BootstrapAdmin creates the first admin from a username and password given at
+// startup, when there is no admin yet. The password only works once: logging
+// in with it hands out a reset token instead of a session. It reports whether
+// the admin was created.
+func BootstrapAdmin(db *gorm.DB, username, password string) (bool, error) {
+	var admins int64
+	if err := db.Unscoped().Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil {
+		return false, err
+	}
+	if admins > 0 {
+		return false, nil
+	}
+	admin := models.User{Username: username, FirstName: "System", LastName: "Admin"}
+	hash, err := HashPassword(password, admin)
+	if err != nil {
+		return false, err
+	}
+	admin.Password = hash
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := OpenCustomerAccount(tx, &admin); err != nil {
+			return err
+		}
+		return tx.Model(&admin).Updates(map[string]interface{}{"role": models.RoleAdmin, "password_reset_required": true}).Error
+	})
+	return err == nil, err
+}
+
+// RequestPasswordReset sends a reset token to the user. Unknown usernames are
+// ignored without an error so callers cannot probe which ones exist.
+func RequestPasswordReset(db *gorm.DB, username string, now time.Time) error {
+	var user models.User
+	err := db.Where("username = ? AND role <> ?", username, models.RoleSystem).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token, err := IssuePasswordResetToken(db, user.ID, now)
+	if err != nil {
+		return err
+	}
+	channel, to := PreferredContact(user)
+	return Notify.Send(Notification{
+		UserID:   user.ID,
+		Username: user.Username,
+		Channel:  channel,
+		To:       to,
+		Subject:  "Password reset",
+		Body:     fmt.Sprintf("Use this token to reset your password within %s:\n%s", PasswordResetTTL, token),
+	})
+}
+
+// ResetPassword uses up a reset token and sets the new password
+func ResetPassword(db *gorm.DB, token, password string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(token), now).First(&reset).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+		user, err := GetUserByID(tx, reset.UserID)
+		if err != nil {
+			if errors.Is(err, ErrUserNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+		if err := SetPassword(tx, &user, password); err != nil {
+			return err
+		}
+		return tx.Model(&reset).Update("used_at", now).Error
+	})
+}
@@ -0,0 +1,43 @@
+package services_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gotestbackend/models"
+	"gotestbackend/services"
+)
+
+func TestValidatePassword(t *testing.T) {
+	user := models.User{Username: "jdoe", FirstName: "Jane", LastName: "Li"}
+	tests := []struct {
+		name     string
+		policy   services.PasswordPolicySettings
+		password string
+		ok       bool
+	}{
+		{"meets the default policy", services.PasswordPolicy, "Tangerine-Kite-42", true},
+		{"too short", services.PasswordPolicy, "Ab3$efgh", false},
+		{"length counts characters, not bytes", services.PasswordPolicy, "Ünïcödé-7ab", true},
+		{"over bcrypt's limit", services.PasswordPolicy, "Aa1-" + strings.Repeat("x", 69), false},
+		{"two classes", services.PasswordPolicy, "tangerinekite42", false},
+		{"two classes allowed", services.PasswordPolicySettings{MinLength: 10, MaxLength: 72, MinClasses: 2}, "tangerinekite42", true},
+		{"common password", services.PasswordPolicy, "Password1!", false},
+		{"common password in other case", services.PasswordPolicy, "pASSWORD1!", false},
+		{"contains username", services.PasswordPolicy, "Tangerine-JDoe-42", false},
+		{"contains first name", services.PasswordPolicy, "Tangerine-jane-42", false},
+		{"short last name is ignored", services.PasswordPolicy, "Tangerine-Li-42", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := services.PasswordPolicy
+			services.PasswordPolicy = tt.policy
+			t.Cleanup(func() { services.PasswordPolicy = previous })
+			err := services.ValidatePassword(tt.password, user)
+			if tt.ok && err != nil || !tt.ok && !errors.Is(err, services.ErrWeakPassword) {
+				t.Errorf("ValidatePassword(%q) = %v, want ok %v", tt.password, err, tt.ok)
+			}
+		})
+	}
+}
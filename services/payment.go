@@ -0,0 +1,262 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RailPayment is what a rail is asked to move
+type RailPayment struct {
+	Reference       string
+	AccountNumber   string
+	ExternalAccount string
+	Amount          float64
+}
+
+// RailResult is a rail's answer to a new payment. Most rails answer pending and
+// report the outcome later through a callback.
+type RailResult struct {
+	ExternalID    string
+	Status        string
+	FailureReason string
+}
+
+// RailCallback is a rail reporting the outcome of a payment
+type RailCallback struct {
+	Reference     string
+	ExternalID    string
+	Status        string
+	FailureReason string
+}
+
+// PaymentRail moves money between this system and an outside one, such as a
+// card processor or a bank transfer network
+type PaymentRail interface {
+	InitiateDeposit(p RailPayment) (RailResult, error)
+	InitiateWithdrawal(p RailPayment) (RailResult, error)
+	// ParseCallback checks the callback's signature and decodes it, returning
+	// ErrInvalidCallbackSignature when it was not sent by the rail
+	ParseCallback(header http.Header, body []byte) (RailCallback, error)
+}
+
+// PaymentRails holds the configured rails by name. None are registered by
+// default; main adds the fake rail only when it is enabled for development.
+var PaymentRails = map[string]PaymentRail{}
+
+// DefaultPaymentRail is used when a request does not name a rail; empty means a rail must be named
+var DefaultPaymentRail = ""
+
+var (
+	// ErrUnknownPaymentRail is returned for a rail that is not configured
+	ErrUnknownPaymentRail = errors.New("unknown payment rail")
+	// ErrInvalidPaymentAmount is returned for amounts that are not positive with at most two decimals
+	ErrInvalidPaymentAmount = errors.New("amount must be positive with at most two decimals")
+	// ErrPaymentAccountUnavailable is returned when the account's status does not allow the payment
+	ErrPaymentAccountUnavailable = errors.New("account cannot make this payment")
+	// ErrPaymentInsufficientCredit is returned when a withdrawal exceeds the available balance
+	ErrPaymentInsufficientCredit = errors.New("insufficient credit")
+	// ErrPaymentNotFound is returned for a callback naming an unknown payment
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrPaymentAlreadySettled is returned when a callback contradicts a payment's final state
+	ErrPaymentAlreadySettled = errors.New("payment already settled")
+	// ErrInvalidCallbackSignature is returned by rails for callbacks they did not sign
+	ErrInvalidCallbackSignature = errors.New("invalid callback signature")
+	// ErrInvalidCallback is returned by rails for callbacks they cannot decode
+	ErrInvalidCallback = errors.New("invalid callback")
+	// ErrPaymentRailMismatch is returned when a callback names a payment made on another rail
+	ErrPaymentRailMismatch = errors.New("payment was made on another rail")
+)
+
+// paymentRail looks up a rail by name, "" meaning DefaultPaymentRail
+func paymentRail(name string) (string, PaymentRail, error) {
+	if name == "" {
+		name = DefaultPaymentRail
+	}
+	rail, ok := PaymentRails[name]
+	if name == "" {
+		ok = false
+	}
+	if !ok {
+		return name, nil, ErrUnknownPaymentRail
+	}
+	return name, rail, nil
+}
+
+// newPayment checks the amount and builds a pending payment with a fresh reference
+func newPayment(user models.User, kind, rail, externalAccount string, amount float64) (models.Payment, error) {
+	if amount <= 0 || roundMoney(amount) != amount {
+		return models.Payment{}, ErrInvalidPaymentAmount
+	}
+	reference, err := randomToken(16)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	return models.Payment{
+		UserID:          user.ID,
+		Kind:            kind,
+		Rail:            rail,
+		Reference:       reference,
+		ExternalAccount: externalAccount,
+		Amount:          amount,
+		Status:          models.PaymentPending,
+	}, nil
+}
+
+// StartDeposit asks the rail to pull money in for the user. The account is
+// credited when the rail reports success.
+func StartDeposit(db *gorm.DB, user models.User, railName, externalAccount string, amount float64) (models.Payment, error) {
+	name, rail, err := paymentRail(railName)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if user.Role == models.RoleSystem || user.Status == models.AccountFrozen || user.Status == models.AccountClosed {
+		return models.Payment{}, ErrPaymentAccountUnavailable
+	}
+	payment, err := newPayment(user, models.PaymentDeposit, name, externalAccount, amount)
+	if err != nil {
+		return payment, err
+	}
+	if err := db.Create(&payment).Error; err != nil {
+		return payment, err
+	}
+	return submitPayment(db, payment, user, rail.InitiateDeposit)
+}
+
+// StartWithdrawal holds the amount on the user's account and asks the rail to
+// pay it out. The hold becomes a ledger posting when the rail reports success
+// and is released if it fails.
+func StartWithdrawal(db *gorm.DB, user models.User, railName, externalAccount string, amount float64) (models.Payment, error) {
+	name, rail, err := paymentRail(railName)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	payment, err := newPayment(user, models.PaymentWithdrawal, name, externalAccount, amount)
+	if err != nil {
+		return payment, err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := LockUsers(tx, &user); err != nil {
+			return err
+		}
+		if user.Role == models.RoleSystem || user.Status != models.AccountActive {
+			return ErrPaymentAccountUnavailable
+		}
+		if AvailableBalance(user) < amount {
+			return ErrPaymentInsufficientCredit
+		}
+		user.HeldAmount = roundMoney(user.HeldAmount + amount)
+		if err := tx.Model(&user).Update("held_amount", user.HeldAmount).Error; err != nil {
+			return err
+		}
+		return tx.Create(&payment).Error
+	})
+	if err != nil {
+		return payment, err
+	}
+	return submitPayment(db, payment, user, rail.InitiateWithdrawal)
+}
+
+// submitPayment hands a created payment to the rail and records its answer.
+// A rail error fails the payment, releasing any hold.
+func submitPayment(db *gorm.DB, payment models.Payment, user models.User, initiate func(RailPayment) (RailResult, error)) (models.Payment, error) {
+	result, err := initiate(RailPayment{
+		Reference:       payment.Reference,
+		AccountNumber:   user.AccountNumber,
+		ExternalAccount: payment.ExternalAccount,
+		Amount:          payment.Amount,
+	})
+	if err != nil {
+		return CompletePayment(db, payment.Rail, RailCallback{Reference: payment.Reference, Status: models.PaymentFailed, FailureReason: err.Error()})
+	}
+	if result.ExternalID != "" {
+		if err := db.Model(&payment).Update("external_id", result.ExternalID).Error; err != nil {
+			return payment, err
+		}
+	}
+	if result.Status == models.PaymentPending || result.Status == "" {
+		return payment, nil
+	}
+	return CompletePayment(db, payment.Rail, RailCallback{
+		Reference:     payment.Reference,
+		ExternalID:    result.ExternalID,
+		Status:        result.Status,
+		FailureReason: result.FailureReason,
+	})
+}
+
+// CompletePayment applies the final answer of the rail named railName. Success
+// posts the payment against the settlement account; failure only releases a
+// withdrawal's hold. Repeating the same outcome is a no-op, so rails may retry
+// callbacks. A rail can only settle payments that were made on it.
+func CompletePayment(db *gorm.DB, railName string, callback RailCallback) (models.Payment, error) {
+	if callback.Status != models.PaymentSucceeded && callback.Status != models.PaymentFailed {
+		return models.Payment{}, fmt.Errorf("%w: status must be succeeded or failed", ErrInvalidCallback)
+	}
+	var payment models.Payment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ?", callback.Reference).First(&payment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrPaymentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if payment.Rail != railName {
+			return ErrPaymentRailMismatch
+		}
+		if payment.Status != models.PaymentPending {
+			if payment.Status == callback.Status {
+				return nil
+			}
+			return ErrPaymentAlreadySettled
+		}
+		settlement, err := SystemAccount(tx, models.SystemAccountSettlement)
+		if err != nil {
+			return err
+		}
+		user := models.User{ID: payment.UserID}
+		if err := LockUsers(tx, &user, &settlement); err != nil {
+			return err
+		}
+		if payment.Kind == models.PaymentWithdrawal {
+			user.HeldAmount = roundMoney(user.HeldAmount - payment.Amount)
+			if err := tx.Model(&user).Update("held_amount", user.HeldAmount).Error; err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		updates := map[string]interface{}{"status": callback.Status, "completed_at": now}
+		if callback.ExternalID != "" {
+			updates["external_id"] = callback.ExternalID
+		}
+		if callback.Status == models.PaymentFailed {
+			updates["failure_reason"] = callback.FailureReason
+		} else {
+			from, to, kind := &settlement, &user, models.TransactionDeposit
+			if payment.Kind == models.PaymentWithdrawal {
+				from, to, kind = &user, &settlement, models.TransactionWithdrawal
+			}
+			txn, err := PostLedger(tx, from, to, payment.Amount, models.Transaction{
+				Kind: kind,
+				Memo: fmt.Sprintf("%s via %s, ref %s", payment.Kind, payment.Rail, payment.Reference),
+			})
+			if err != nil {
+				return err
+			}
+			updates["transaction_id"] = txn.ID
+		}
+		return tx.Model(&payment).Updates(updates).Error
+	})
+	if err != nil {
+		return payment, err
+	}
+	err = db.First(&payment, payment.ID).Error
+	return payment, err
+}
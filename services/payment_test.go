@@ -0,0 +1,83 @@
+package services_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/models"
+	"gotestbackend/services"
+	"gotestbackend/services/fakerail"
+)
+
+// useFakeRail registers a fake rail that never calls back on its own, so the
+// test delivers callbacks with Sign
+func useFakeRail(t *testing.T, name string) *fakerail.Rail {
+	t.Helper()
+	rail := fakerail.New([]byte("test-secret"), "", 0)
+	services.PaymentRails[name] = rail
+	t.Cleanup(func() { delete(services.PaymentRails, name) })
+	return rail
+}
+
+func TestDepositSettlesThroughSignedCallback(t *testing.T) {
+	db := testutil.NewDB(t)
+	rail := useFakeRail(t, "fake")
+	user := testutil.CreateUser(t, db, "depositor", "123456789", 0)
+
+	payment, err := services.StartDeposit(db, user, "fake", "bank-1", 250)
+	if err != nil {
+		t.Fatalf("StartDeposit: %v", err)
+	}
+	body, signature, err := rail.Sign(fakerail.Callback{Reference: payment.Reference, ExternalID: "ext-1", Status: models.PaymentSucceeded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback, err := rail.ParseCallback(http.Header{fakerail.SignatureHeader: {signature}}, body)
+	if err != nil {
+		t.Fatalf("ParseCallback: %v", err)
+	}
+	settled, err := services.CompletePayment(db, "fake", callback)
+	if err != nil {
+		t.Fatalf("CompletePayment: %v", err)
+	}
+	if settled.Status != models.PaymentSucceeded {
+		t.Errorf("status = %s, want %s", settled.Status, models.PaymentSucceeded)
+	}
+	var after models.User
+	db.First(&after, user.ID)
+	if after.Credit != 250 {
+		t.Errorf("credit = %v, want 250", after.Credit)
+	}
+}
+
+func TestCompletePaymentRejectsOtherRail(t *testing.T) {
+	db := testutil.NewDB(t)
+	useFakeRail(t, "fake")
+	useFakeRail(t, "other")
+	user := testutil.CreateUser(t, db, "depositor", "123456789", 0)
+
+	payment, err := services.StartDeposit(db, user, "fake", "bank-1", 250)
+	if err != nil {
+		t.Fatalf("StartDeposit: %v", err)
+	}
+	_, err = services.CompletePayment(db, "other", services.RailCallback{Reference: payment.Reference, Status: models.PaymentSucceeded})
+	if !errors.Is(err, services.ErrPaymentRailMismatch) {
+		t.Fatalf("err = %v, want ErrPaymentRailMismatch", err)
+	}
+	var after models.User
+	db.First(&after, user.ID)
+	if after.Credit != 0 {
+		t.Errorf("credit = %v, want 0", after.Credit)
+	}
+}
+
+func TestStartDepositNeedsRailWhenNoneIsDefault(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.CreateUser(t, db, "depositor", "123456789", 0)
+
+	if _, err := services.StartDeposit(db, user, "", "bank-1", 250); err == nil {
+		t.Fatal("StartDeposit without a rail succeeded, want an error")
+	}
+}
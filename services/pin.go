@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gotestbackend/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PINThreshold is the transfer amount from which the transaction PIN is
+// required. Set from PIN_THRESHOLD at startup.
+var PINThreshold = 5000.0
+
+// PINMaxAttempts is how many wrong PINs in a row lock the PIN
+var PINMaxAttempts = 5
+
+// PINLockout is how long a locked PIN stays locked
+var PINLockout = 30 * time.Minute
+
+var (
+	// ErrInvalidPINFormat is returned when a PIN is not exactly 6 digits
+	ErrInvalidPINFormat = errors.New("PIN must be 6 digits")
+	// ErrPINNotSet is returned when the user has no transaction PIN yet
+	ErrPINNotSet = errors.New("transaction PIN not set")
+	// ErrWrongPIN is returned when the PIN does not match
+	ErrWrongPIN = errors.New("wrong transaction PIN")
+	// ErrPINLocked is returned while the PIN is locked after too many wrong attempts
+	ErrPINLocked = errors.New("transaction PIN locked")
+)
+
+// ValidPINFormat reports whether pin is exactly 6 digits
+func ValidPINFormat(pin string) bool {
+	if len(pin) != 6 {
+		return false
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// HasTransactionPIN reports whether the user has set a PIN
+func HasTransactionPIN(db *gorm.DB, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.TransactionPIN{}).Where("user_id = ?", userID).Count(&count).Error
+	return count > 0, err
+}
+
+// SetTransactionPIN sets or replaces a user's PIN and clears any lockout.
+// Callers must have checked the user's password first.
+func SetTransactionPIN(db *gorm.DB, userID uint, pin string) error {
+	if !ValidPINFormat(pin) {
+		return ErrInvalidPINFormat
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	record := models.TransactionPIN{UserID: userID, Hash: string(hash)}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"hash": record.Hash, "failed_attempts": 0, "locked_until": nil, "updated_at": time.Now()}),
+	}).Create(&record).Error
+}
+
+// VerifyTransactionPIN checks pin against the user's stored PIN. Wrong attempts
+// are counted; PINMaxAttempts in a row lock the PIN for PINLockout.
+func VerifyTransactionPIN(db *gorm.DB, userID uint, pin string, now time.Time) error {
+	var result error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var record models.TransactionPIN
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, "user_id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				result = ErrPINNotSet
+				return nil
+			}
+			return err
+		}
+		if record.LockedUntil != nil && now.Before(*record.LockedUntil) {
+			result = ErrPINLocked
+			return nil
+		}
+		if bcrypt.CompareHashAndPassword([]byte(record.Hash), []byte(pin)) == nil {
+			return tx.Model(&record).Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil}).Error
+		}
+		updates := map[string]interface{}{"failed_attempts": record.FailedAttempts + 1}
+		result = ErrWrongPIN
+		if record.FailedAttempts+1 >= PINMaxAttempts {
+			updates["failed_attempts"] = 0
+			updates["locked_until"] = now.Add(PINLockout)
+			result = ErrPINLocked
+		}
+		return tx.Model(&record).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
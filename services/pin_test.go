@@ -0,0 +1,66 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/services"
+)
+
+func TestValidPINFormat(t *testing.T) {
+	tests := map[string]bool{"123456": true, "000000": true, "12345": false, "1234567": false, "12345a": false, "١٢٣٤٥٦": false, "": false}
+	for pin, want := range tests {
+		if got := services.ValidPINFormat(pin); got != want {
+			t.Errorf("ValidPINFormat(%q) = %v, want %v", pin, got, want)
+		}
+	}
+}
+
+func TestTransactionPINLockout(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.CreateUser(t, db, "alice", "123456789", 0)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := services.VerifyTransactionPIN(db, user.ID, "123456", now); !errors.Is(err, services.ErrPINNotSet) {
+		t.Fatalf("before setting: err = %v, want ErrPINNotSet", err)
+	}
+	if err := services.SetTransactionPIN(db, user.ID, "123456"); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name  string
+		pin   string
+		after time.Duration
+		want  error
+	}{
+		{"correct", "123456", 0, nil},
+		{"wrong 1", "000000", 0, services.ErrWrongPIN},
+		{"wrong 2", "000000", 0, services.ErrWrongPIN},
+		{"correct resets the count", "123456", 0, nil},
+		{"wrong 1 again", "000000", 0, services.ErrWrongPIN},
+		{"wrong 2 again", "000000", 0, services.ErrWrongPIN},
+		{"wrong 3", "000000", 0, services.ErrWrongPIN},
+		{"wrong 4", "000000", 0, services.ErrWrongPIN},
+		{"wrong 5 locks", "000000", 0, services.ErrPINLocked},
+		{"correct while locked", "123456", services.PINLockout - time.Second, services.ErrPINLocked},
+		{"correct after the lockout", "123456", services.PINLockout, nil},
+	}
+	for _, step := range steps {
+		if err := services.VerifyTransactionPIN(db, user.ID, step.pin, now.Add(step.after)); !errors.Is(err, step.want) {
+			t.Errorf("%s: err = %v, want %v", step.name, err, step.want)
+		}
+	}
+
+	// Resetting the PIN clears a lockout straight away
+	for i := 0; i < services.PINMaxAttempts; i++ {
+		services.VerifyTransactionPIN(db, user.ID, "000000", now)
+	}
+	if err := services.SetTransactionPIN(db, user.ID, "654321"); err != nil {
+		t.Fatal(err)
+	}
+	if err := services.VerifyTransactionPIN(db, user.ID, "654321", now); err != nil {
+		t.Errorf("after reset: err = %v", err)
+	}
+}
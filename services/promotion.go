@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidPromotion is returned when a campaign has a bad amount, budget, window or kind
+	ErrInvalidPromotion = errors.New("invalid promotion")
+	// ErrPromotionInactive is returned when a campaign is switched off or outside its dates
+	ErrPromotionInactive = errors.New("promotion is not running")
+	// ErrPromotionBudgetExhausted is returned when a grant would take a campaign past its budget
+	ErrPromotionBudgetExhausted = errors.New("promotion budget exhausted")
+	// ErrPromotionNotEligible is returned when the user does not qualify for a campaign
+	ErrPromotionNotEligible = errors.New("user is not eligible for this promotion")
+	// ErrPromotionAlreadyGranted is returned when the user already received a campaign
+	ErrPromotionAlreadyGranted = errors.New("promotion already granted to this user")
+)
+
+// PromotionSpend is one campaign's line in the spend report
+type PromotionSpend struct {
+	PromotionID uint    `json:"promotion_id"`
+	Name        string  `json:"name"`
+	Kind        string  `json:"kind"`
+	BudgetCap   float64 `json:"budget_cap"`
+	// Grants and Spent cover the report's date range; Remaining is the budget left today
+	Grants    int     `json:"grants"`
+	Spent     float64 `json:"spent"`
+	Remaining float64 `json:"remaining"`
+}
+
+// ValidatePromotion checks a campaign before it is saved
+func ValidatePromotion(p models.Promotion) error {
+	switch {
+	case strings.TrimSpace(p.Name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidPromotion)
+	case p.Kind != models.PromotionSignup && p.Kind != models.PromotionManual:
+		return fmt.Errorf("%w: kind must be signup or manual", ErrInvalidPromotion)
+	case p.Amount <= 0 || roundMoney(p.Amount) != p.Amount:
+		return fmt.Errorf("%w: amount must be positive with at most two decimals", ErrInvalidPromotion)
+	case p.BudgetCap < p.Amount:
+		return fmt.Errorf("%w: budget_cap must cover at least one grant", ErrInvalidPromotion)
+	case p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt):
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	case p.AccountType != "" && p.AccountType != models.AccountTypePersonal && p.AccountType != models.AccountTypeBusiness:
+		return fmt.Errorf("%w: account_type must be personal, business or empty", ErrInvalidPromotion)
+	}
+	return nil
+}
+
+// promotionRunning tells whether grants can be made at now
+func promotionRunning(p models.Promotion, now time.Time) bool {
+	return p.Active && (p.StartsAt == nil || !now.Before(*p.StartsAt)) && (p.EndsAt == nil || now.Before(*p.EndsAt))
+}
+
+// promotionEligible checks the per-user rules of a campaign
+func promotionEligible(p models.Promotion, user models.User) error {
+	switch {
+	case user.Role == models.RoleSystem:
+		return fmt.Errorf("%w: house account", ErrPromotionNotEligible)
+	case user.Status == models.AccountFrozen || user.Status == models.AccountClosed:
+		return fmt.Errorf("%w: account is %s", ErrPromotionNotEligible, user.Status)
+	case p.AccountType != "" && user.AccountType != p.AccountType:
+		return fmt.Errorf("%w: campaign is for %s accounts", ErrPromotionNotEligible, p.AccountType)
+	}
+	if p.Kind == models.PromotionSignup {
+		// A verified contact stops one person farming the bonus with throwaway accounts
+		if !HasVerifiedContact(user) {
+			return fmt.Errorf("%w: no verified email or phone", ErrPromotionNotEligible)
+		}
+		// Accounts opened with the old built-in credit already had their bonus
+		if user.OpeningBalance != 0 {
+			return fmt.Errorf("%w: account was opened with credit", ErrPromotionNotEligible)
+		}
+		if user.OpenedAt == nil || (p.StartsAt != nil && user.OpenedAt.Before(*p.StartsAt)) {
+			return fmt.Errorf("%w: account was opened before the campaign", ErrPromotionNotEligible)
+		}
+	}
+	return nil
+}
+
+// GrantPromotion pays a campaign's amount to a user from the promotions account.
+// The campaign row is locked while its budget is checked and spent, so
+// concurrent grants cannot overspend it. grantedBy is nil for automatic grants.
+func GrantPromotion(db *gorm.DB, promotionID, userID uint, grantedBy *uint) (models.PromotionGrant, error) {
+	var grant models.PromotionGrant
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var promotion models.Promotion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promotion, promotionID).Error; err != nil {
+			return err
+		}
+		if !promotionRunning(promotion, time.Now()) {
+			return ErrPromotionInactive
+		}
+		if roundMoney(promotion.Spent+promotion.Amount) > promotion.BudgetCap {
+			return ErrPromotionBudgetExhausted
+		}
+		house, err := SystemAccount(tx, models.SystemAccountPromotions)
+		if err != nil {
+			return err
+		}
+		user := models.User{ID: userID}
+		if err := LockUsers(tx, &user, &house); err != nil {
+			return err
+		}
+		if err := promotionEligible(promotion, user); err != nil {
+			return err
+		}
+		var granted int64
+		if err := tx.Model(&models.PromotionGrant{}).Where("promotion_id = ? AND user_id = ?", promotionID, userID).Count(&granted).Error; err != nil {
+			return err
+		}
+		if granted > 0 {
+			return ErrPromotionAlreadyGranted
+		}
+		txn, err := PostLedger(tx, &house, &user, promotion.Amount, models.Transaction{
+			Kind: models.TransactionPromotion,
+			Memo: fmt.Sprintf("Promotion #%d: %s", promotion.ID, promotion.Name),
+		})
+		if err != nil {
+			return err
+		}
+		grant = models.PromotionGrant{
+			PromotionID:   promotion.ID,
+			UserID:        userID,
+			Amount:        promotion.Amount,
+			TransactionID: txn.ID,
+			GrantedBy:     grantedBy,
+		}
+		if err := tx.Create(&grant).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrPromotionAlreadyGranted
+			}
+			return err
+		}
+		return tx.Model(&promotion).Updates(map[string]interface{}{
+			"spent":       roundMoney(promotion.Spent + promotion.Amount),
+			"grant_count": promotion.GrantCount + 1,
+		}).Error
+	})
+	return grant, err
+}
+
+// runningSignupPromotions lists the sign-up campaigns that can grant right now
+func runningSignupPromotions(db *gorm.DB, now time.Time) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	err := db.Where("kind = ? AND active = ? AND (starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)",
+		models.PromotionSignup, true, now, now).Order("id").Find(&promotions).Error
+	return promotions, err
+}
+
+// skippedGrant tells whether a grant failed only because the user or campaign does not qualify
+func skippedGrant(err error) bool {
+	return errors.Is(err, ErrPromotionInactive) || errors.Is(err, ErrPromotionBudgetExhausted) ||
+		errors.Is(err, ErrPromotionNotEligible) || errors.Is(err, ErrPromotionAlreadyGranted)
+}
+
+// GrantSignupPromotions pays every running sign-up campaign the user qualifies
+// for. It runs when the user first verifies a contact; campaigns the user does
+// not qualify for are skipped.
+func GrantSignupPromotions(db *gorm.DB, user models.User) ([]models.PromotionGrant, error) {
+	grants := []models.PromotionGrant{}
+	promotions, err := runningSignupPromotions(db, time.Now())
+	if err != nil {
+		return grants, err
+	}
+	for _, promotion := range promotions {
+		grant, err := GrantPromotion(db, promotion.ID, user.ID, nil)
+		if skippedGrant(err) {
+			continue
+		}
+		if err != nil {
+			return grants, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, nil
+}
+
+// GrantMissedSignupPromotions pays running sign-up campaigns to accounts that
+// qualify but were not granted when they verified a contact, e.g. sample users
+// or a grant that failed. It is safe to run again.
+func GrantMissedSignupPromotions(db *gorm.DB) (int, error) {
+	promotions, err := runningSignupPromotions(db, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	granted := 0
+	for _, promotion := range promotions {
+		query := db.Model(&models.User{}).
+			Where("role <> ? AND opening_balance = 0 AND opened_at IS NOT NULL", models.RoleSystem).
+			Where("email_verified_at IS NOT NULL OR phone_verified_at IS NOT NULL").
+			Where("NOT EXISTS (SELECT 1 FROM promotion_grants g WHERE g.promotion_id = ? AND g.user_id = users.id)", promotion.ID)
+		if promotion.StartsAt != nil {
+			query = query.Where("opened_at >= ?", *promotion.StartsAt)
+		}
+		var userIDs []uint
+		if err := query.Order("id").Pluck("id", &userIDs).Error; err != nil {
+			return granted, err
+		}
+		for _, userID := range userIDs {
+			_, err := GrantPromotion(db, promotion.ID, userID, nil)
+			if errors.Is(err, ErrPromotionBudgetExhausted) || errors.Is(err, ErrPromotionInactive) {
+				log.Printf("Promotion %d stopped granting: %v", promotion.ID, err)
+				break
+			}
+			if skippedGrant(err) {
+				continue
+			}
+			if err != nil {
+				return granted, err
+			}
+			granted++
+		}
+	}
+	return granted, nil
+}
+
+// PromotionSpendReport sums the grants of every campaign made in [from, to).
+// Zero times leave that end of the range open.
+func PromotionSpendReport(db *gorm.DB, from, to time.Time) ([]PromotionSpend, error) {
+	var promotions []models.Promotion
+	if err := db.Order("id").Find(&promotions).Error; err != nil {
+		return nil, err
+	}
+	type total struct {
+		PromotionID uint
+		Grants      int
+		Spent       float64
+	}
+	query := db.Model(&models.PromotionGrant{}).Select("promotion_id, COUNT(*) AS grants, COALESCE(SUM(amount), 0) AS spent").Group("promotion_id")
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+	var totals []total
+	if err := query.Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	byPromotion := map[uint]total{}
+	for _, t := range totals {
+		byPromotion[t.PromotionID] = t
+	}
+	report := make([]PromotionSpend, 0, len(promotions))
+	for _, p := range promotions {
+		t := byPromotion[p.ID]
+		report = append(report, PromotionSpend{
+			PromotionID: p.ID,
+			Name:        p.Name,
+			Kind:        p.Kind,
+			BudgetCap:   p.BudgetCap,
+			Grants:      t.Grants,
+			Spent:       roundMoney(t.Spent),
+			Remaining:   roundMoney(p.BudgetCap - p.Spent),
+		})
+	}
+	return report, nil
+}
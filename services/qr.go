@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EMVCo merchant-presented QR tags used by PromptPay
+const (
+	qrTagPayloadFormat   = "00"
+	qrTagInitiation      = "01"
+	qrTagMerchantAccount = "29"
+	qrTagCurrency        = "53"
+	qrTagAmount          = "54"
+	qrTagCountry         = "58"
+	qrTagCRC             = "63"
+
+	// sub-tags inside the merchant account template
+	qrSubTagAID     = "00"
+	qrSubTagAccount = "04"
+
+	qrPromptPayAID   = "A000000677010111"
+	qrStaticPayment  = "11"
+	qrDynamicPayment = "12"
+	qrCurrencyTHB    = "764"
+	qrCountryTH      = "TH"
+)
+
+var (
+	// ErrInvalidQRPayload is returned when a payload is not valid EMVCo TLV
+	ErrInvalidQRPayload = errors.New("invalid QR payload")
+	// ErrQRChecksum is returned when the CRC does not match the payload
+	ErrQRChecksum = errors.New("QR payload checksum mismatch")
+	// ErrQRFieldTooLong is returned when a value does not fit a two-digit TLV length
+	ErrQRFieldTooLong = errors.New("QR field longer than 99 characters")
+)
+
+// QRPayment is the transfer information carried by a payment QR
+type QRPayment struct {
+	AccountNumber string
+	Amount        float64
+}
+
+// qrField encodes one TLV field
+func qrField(tag, value string) (string, error) {
+	if len(value) > 99 {
+		return "", fmt.Errorf("%w: tag %s", ErrQRFieldTooLong, tag)
+	}
+	return fmt.Sprintf("%s%02d%s", tag, len(value), value), nil
+}
+
+// crc16 is CRC-16/CCITT-FALSE as required by EMVCo (poly 0x1021, init 0xFFFF)
+func crc16(data string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// BuildQRPayload returns the EMVCo payload string for an account, with an optional fixed amount
+func BuildQRPayload(accountNumber string, amount float64) (string, error) {
+	initiation := qrStaticPayment
+	if amount > 0 {
+		initiation = qrDynamicPayment
+	}
+	aid, err := qrField(qrSubTagAID, qrPromptPayAID)
+	if err != nil {
+		return "", err
+	}
+	account, err := qrField(qrSubTagAccount, accountNumber)
+	if err != nil {
+		return "", err
+	}
+	fields := [][2]string{
+		{qrTagPayloadFormat, "01"},
+		{qrTagInitiation, initiation},
+		{qrTagMerchantAccount, aid + account},
+		{qrTagCurrency, qrCurrencyTHB},
+	}
+	if amount > 0 {
+		fields = append(fields, [2]string{qrTagAmount, strconv.FormatFloat(amount, 'f', 2, 64)})
+	}
+	fields = append(fields, [2]string{qrTagCountry, qrCountryTH})
+	var b strings.Builder
+	for _, field := range fields {
+		encoded, err := qrField(field[0], field[1])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(encoded)
+	}
+	// The CRC covers everything up to and including its own tag and length
+	b.WriteString(qrTagCRC + "04")
+	return b.String() + fmt.Sprintf("%04X", crc16(b.String())), nil
+}
+
+// parseTLV splits a TLV string into a tag to value map
+func parseTLV(data string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, ErrInvalidQRPayload
+		}
+		// The length is exactly two ASCII digits; Atoi would also take "-1" or "+9"
+		if !isDigit(data[2]) || !isDigit(data[3]) {
+			return nil, ErrInvalidQRPayload
+		}
+		length := int(data[2]-'0')*10 + int(data[3]-'0')
+		if len(data) < 4+length {
+			return nil, ErrInvalidQRPayload
+		}
+		fields[data[:2]] = data[4 : 4+length]
+		data = data[4+length:]
+	}
+	return fields, nil
+}
+
+// isDigit reports whether b is an ASCII digit
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// ParseQRPayload verifies the CRC of a scanned payload and extracts the account and amount
+func ParseQRPayload(payload string) (QRPayment, error) {
+	payload = strings.TrimSpace(payload)
+	if len(payload) < 8 || payload[len(payload)-8:len(payload)-4] != qrTagCRC+"04" {
+		return QRPayment{}, ErrInvalidQRPayload
+	}
+	body, checksum := payload[:len(payload)-4], payload[len(payload)-4:]
+	if !strings.EqualFold(checksum, fmt.Sprintf("%04X", crc16(body))) {
+		return QRPayment{}, ErrQRChecksum
+	}
+	fields, err := parseTLV(payload)
+	if err != nil {
+		return QRPayment{}, err
+	}
+	if fields[qrTagCurrency] != "" && fields[qrTagCurrency] != qrCurrencyTHB {
+		return QRPayment{}, fmt.Errorf("%w: unsupported currency %s", ErrInvalidQRPayload, fields[qrTagCurrency])
+	}
+	merchant, err := parseTLV(fields[qrTagMerchantAccount])
+	if err != nil {
+		return QRPayment{}, err
+	}
+	if merchant[qrSubTagAID] != qrPromptPayAID || merchant[qrSubTagAccount] == "" {
+		return QRPayment{}, ErrInvalidQRPayload
+	}
+	payment := QRPayment{AccountNumber: merchant[qrSubTagAccount]}
+	if raw, ok := fields[qrTagAmount]; ok {
+		amount, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) || amount <= 0 {
+			return QRPayment{}, ErrInvalidQRPayload
+		}
+		payment.Amount = amount
+	}
+	return payment, nil
+}
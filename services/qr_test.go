@@ -0,0 +1,93 @@
+package services_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gotestbackend/services"
+)
+
+// Payload parts for account 1234567897, checksums worked out independently
+const (
+	qrStatic   = "000201" + "010211"
+	qrDynamic  = "000201" + "010212"
+	qrMerchant = "2934" + "0016A000000677010111" + "04101234567897"
+	qrTHB      = "5303764"
+	qrCountry  = "5802TH" + "6304"
+)
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		data string
+		want uint16
+	}{
+		{"", 0xFFFF},
+		{"A", 0xB915},
+		{"123456789", 0x29B1},
+	}
+	for _, tt := range tests {
+		if got := services.CRC16(tt.data); got != tt.want {
+			t.Errorf("CRC16(%q) = %04X, want %04X", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestBuildQRPayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount float64
+		want   string
+	}{
+		{"static", 0, qrStatic + qrMerchant + qrTHB + qrCountry + "3C4C"},
+		{"with amount", 25.5, qrDynamic + qrMerchant + qrTHB + "540525.50" + qrCountry + "60C4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := services.BuildQRPayload("1234567897", tt.amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildQRPayload() = %s, want %s", got, tt.want)
+			}
+			payment, err := services.ParseQRPayload(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payment.AccountNumber != "1234567897" || payment.Amount != tt.amount {
+				t.Errorf("ParseQRPayload() = %+v", payment)
+			}
+		})
+	}
+
+	if _, err := services.BuildQRPayload(strings.Repeat("1", 100), 0); !errors.Is(err, services.ErrQRFieldTooLong) {
+		t.Errorf("over-long account: err = %v, want ErrQRFieldTooLong", err)
+	}
+}
+
+func TestParseQRPayload(t *testing.T) {
+	static := qrStatic + qrMerchant + qrTHB + qrCountry
+	tests := []struct {
+		name    string
+		payload string
+		want    services.QRPayment
+		err     error
+	}{
+		{"lower-case checksum", static + "3c4c", services.QRPayment{AccountNumber: "1234567897"}, nil},
+		{"surrounding spaces", " " + static + "3C4C\n", services.QRPayment{AccountNumber: "1234567897"}, nil},
+		{"tampered account", strings.Replace(static, "1234567897", "1234567898", 1) + "3C4C", services.QRPayment{}, services.ErrQRChecksum},
+		{"no checksum", qrStatic + qrMerchant + qrTHB, services.QRPayment{}, services.ErrInvalidQRPayload},
+		{"other currency", qrDynamic + qrMerchant + "5303840" + qrCountry + "68FA", services.QRPayment{}, services.ErrInvalidQRPayload},
+		{"zero amount", qrDynamic + qrMerchant + qrTHB + "54040.00" + qrCountry + "F16F", services.QRPayment{}, services.ErrInvalidQRPayload},
+		{"signed length", qrDynamic + qrMerchant + qrTHB + "54-1" + qrCountry + "1E43", services.QRPayment{}, services.ErrInvalidQRPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := services.ParseQRPayload(tt.payload)
+			if !errors.Is(err, tt.err) || (tt.err == nil && got != tt.want) {
+				t.Errorf("ParseQRPayload() = %+v, %v, want %+v, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
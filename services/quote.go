@@ -0,0 +1,31 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuoteRedeemed is returned when a transfer quote has already been used
+var ErrQuoteRedeemed = errors.New("quote already used")
+
+// RedeemQuote marks a quote as used. Run it inside the transfer's transaction
+// so a transfer that fails leaves the quote usable until it expires.
+func RedeemQuote(tx *gorm.DB, quoteID string, expiresAt time.Time) error {
+	// Expired quotes are refused when parsed, so their rows are no longer needed
+	if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.RedeemedQuote{}).Error; err != nil {
+		return err
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RedeemedQuote{QuoteID: quoteID, ExpiresAt: expiresAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrQuoteRedeemed
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package services_test
+
+import (
+	"testing"
+
+	"gotestbackend/internal/testutil"
+	"gotestbackend/services"
+)
+
+func TestReconcileFixBalancesTheLedger(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.CreateUser(t, db, "gwen", "123456789", 50)
+
+	report, err := services.Reconcile(db, true)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	var corrected bool
+	for _, correction := range report.Corrections {
+		if correction.ReceiverID == user.ID {
+			corrected = true
+			if correction.Amount != 50 || correction.ReceiverRemaining != 50 {
+				t.Errorf("correction = %.2f leaving %.2f, want 50.00 leaving 50.00", correction.Amount, correction.ReceiverRemaining)
+			}
+		}
+	}
+	if !corrected {
+		t.Fatalf("no correction for the user in %+v", report.Corrections)
+	}
+
+	report, err = services.Reconcile(db, true)
+	if err != nil {
+		t.Fatalf("second Reconcile: %v", err)
+	}
+	for _, d := range report.Discrepancies {
+		if d.UserID == user.ID {
+			t.Errorf("user still out of balance after the fix: %+v", d)
+		}
+	}
+	for _, correction := range report.Corrections {
+		if correction.SenderID == user.ID || correction.ReceiverID == user.ID {
+			t.Errorf("user corrected twice: %+v", correction)
+		}
+	}
+	if credit := testutil.Credit(t, db, user.ID); credit != 50 {
+		t.Errorf("user credit = %.2f, want 50.00 untouched", credit)
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrSessionNotFound is returned when the user has no such active session
+var ErrSessionNotFound = errors.New("session not found")
+
+// CreateSession records a new login
+func CreateSession(db *gorm.DB, userID uint, deviceLabel, ip, userAgent string, expiresAt time.Time) (models.Session, error) {
+	now := time.Now()
+	if deviceLabel == "" {
+		deviceLabel = "Unknown device"
+	}
+	session := models.Session{
+		UserID:      userID,
+		DeviceLabel: deviceLabel,
+		IP:          ip,
+		UserAgent:   userAgent,
+		CreatedAt:   now,
+		LastSeenAt:  now,
+		ExpiresAt:   expiresAt,
+	}
+	err := db.Create(&session).Error
+	return session, err
+}
+
+// ActiveSessions lists a user's sessions that are neither revoked nor expired, newest first
+func ActiveSessions(db *gorm.DB, userID uint, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession signs out one of the user's sessions
+func RevokeSession(db *gorm.DB, userID, sessionID uint) error {
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeSessions signs out every session of the user except keepID (0 keeps none)
+func RevokeSessions(db *gorm.DB, userID, keepID uint) (int64, error) {
+	result := db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gotestbackend/models"
+
+	"gorm.io/gorm"
+)
+
+// StatementAlgorithm names the signature scheme in signed statements
+const StatementAlgorithm = "Ed25519"
+
+// StatementSigningKey is the Ed25519 key statements are signed with. It is
+// loaded from the environment at startup; only its public half is published,
+// so statements can be checked without calling this server.
+var StatementSigningKey ed25519.PrivateKey
+
+var (
+	// ErrInvalidStatementSignature is returned when a statement was altered or signed by another key
+	ErrInvalidStatementSignature = errors.New("invalid statement signature")
+	// ErrNoStatementKey is returned when no signing key has been configured
+	ErrNoStatementKey = errors.New("statement signing key is not configured")
+	// ErrInvalidStatementKey is returned for a signing key that is not a hex Ed25519 seed
+	ErrInvalidStatementKey = errors.New("statement signing key must be a 32-byte Ed25519 seed in hex")
+)
+
+// StatementLine is one transaction as it appears on the account's statement
+type StatementLine struct {
+	TransactionID uint      `json:"transaction_id"`
+	Date          time.Time `json:"date"`
+	Kind          string    `json:"kind"`
+	Memo          string    `json:"memo,omitempty"`
+	// Counterparty is the other account's number
+	Counterparty string `json:"counterparty"`
+	// Amount is negative for money leaving the account
+	Amount  float64 `json:"amount"`
+	Balance float64 `json:"balance"`
+	Hash    string  `json:"hash"`
+}
+
+// Statement lists an account's transactions over a date range
+type Statement struct {
+	AccountNumber  string          `json:"account_number"`
+	AccountName    string          `json:"account_name"`
+	From           string          `json:"from"`
+	To             string          `json:"to"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+	OpeningBalance float64         `json:"opening_balance"`
+	ClosingBalance float64         `json:"closing_balance"`
+	Lines          []StatementLine `json:"lines"`
+	// ChainHash is the hash of the last line, tying the statement to the account's transaction chain
+	ChainHash string `json:"chain_hash"`
+}
+
+// SignedStatement carries a statement exactly as it was signed. Statement is
+// kept as raw bytes: the signature covers them, not a re-encoding.
+type SignedStatement struct {
+	Statement json.RawMessage `json:"statement" swaggertype:"object"`
+	Algorithm string          `json:"algorithm"`
+	KeyID     string          `json:"key_id"`
+	Signature string          `json:"signature"`
+}
+
+// ParseStatementKey decodes a hex Ed25519 seed into a signing key
+func ParseStatementKey(seed string) (ed25519.PrivateKey, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(seed))
+	if err != nil || len(raw) != ed25519.SeedSize {
+		return nil, ErrInvalidStatementKey
+	}
+	return ed25519.NewKeyFromSeed(raw), nil
+}
+
+// StatementPublicKey is the key third parties verify statements with, or nil
+// when no signing key is configured
+func StatementPublicKey() ed25519.PublicKey {
+	if StatementSigningKey == nil {
+		return nil
+	}
+	return StatementSigningKey.Public().(ed25519.PublicKey)
+}
+
+// StatementKeyID identifies a public key, so a rotated key can be told apart
+func StatementKeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// BuildStatement lists the user's transactions from the start of from to the
+// end of to, with balances taken from the transactions' remaining snapshots
+func BuildStatement(db *gorm.DB, user models.User, from, to time.Time) (Statement, error) {
+	from, to = truncateDay(from), truncateDay(to)
+	statement := Statement{
+		AccountNumber: user.AccountNumber,
+		AccountName:   user.FirstName + " " + user.LastName,
+		From:          from.Format("2006-01-02"),
+		To:            to.Format("2006-01-02"),
+		GeneratedAt:   time.Now().UTC(),
+		Lines:         []StatementLine{},
+	}
+	if to.Before(from) {
+		return statement, ErrInvalidDateRange
+	}
+	opening, err := closingBalance(db, user, from.AddDate(0, 0, -1))
+	if err != nil {
+		return statement, err
+	}
+	var txns []models.Transaction
+	if err := db.Where("(sender_id = ? OR receiver_id = ?) AND created_at >= ? AND created_at < ?", user.ID, user.ID, from, to.AddDate(0, 0, 1)).
+		Order("id").Find(&txns).Error; err != nil {
+		return statement, err
+	}
+	accounts := map[uint]string{}
+	balance := opening
+	for _, t := range txns {
+		line := StatementLine{TransactionID: t.ID, Date: t.CreatedAt.UTC(), Kind: t.Kind, Memo: t.Memo, Hash: t.Hash}
+		other := t.SenderID
+		if t.SenderID == user.ID {
+			line.Amount, line.Balance, other = -t.Amount, t.SenderRemaining, t.ReceiverID
+		} else {
+			line.Amount, line.Balance = t.Amount, t.ReceiverRemaining
+		}
+		if _, ok := accounts[other]; !ok {
+			var counterparty models.User
+			if err := db.Unscoped().Select("account_number").First(&counterparty, other).Error; err != nil {
+				return statement, lookupError(err)
+			}
+			accounts[other] = counterparty.AccountNumber
+		}
+		line.Counterparty = accounts[other]
+		balance = line.Balance
+		statement.ChainHash = t.Hash
+		statement.Lines = append(statement.Lines, line)
+	}
+	statement.OpeningBalance = opening
+	statement.ClosingBalance = balance
+	return statement, nil
+}
+
+// SignStatement encodes the statement and signs the encoded bytes
+func SignStatement(statement Statement) (SignedStatement, error) {
+	if StatementSigningKey == nil {
+		return SignedStatement{}, ErrNoStatementKey
+	}
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return SignedStatement{}, err
+	}
+	return SignedStatement{
+		Statement: payload,
+		Algorithm: StatementAlgorithm,
+		KeyID:     StatementKeyID(StatementPublicKey()),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(StatementSigningKey, payload)),
+	}, nil
+}
+
+// VerifyStatement checks a signed statement against a public key and decodes it.
+// It needs nothing but the key, so it works offline.
+func VerifyStatement(signed SignedStatement, key ed25519.PublicKey) (Statement, error) {
+	var statement Statement
+	if signed.Algorithm != StatementAlgorithm {
+		return statement, ErrInvalidStatementSignature
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil || !ed25519.Verify(key, signed.Statement, signature) {
+		return statement, ErrInvalidStatementSignature
+	}
+	err = json.Unmarshal(signed.Statement, &statement)
+	return statement, err
+}
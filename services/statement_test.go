@@ -0,0 +1,84 @@
+package services_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"gotestbackend/services"
+)
+
+// rfc8032Seed is the secret key of RFC 8032 test 1
+const rfc8032Seed = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+
+func TestParseStatementKey(t *testing.T) {
+	key, err := services.ParseStatementKey(" " + rfc8032Seed + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(key.Public().(ed25519.PublicKey)); got != "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a" {
+		t.Errorf("public key = %s", got)
+	}
+	for _, seed := range []string{"", "not hex", rfc8032Seed[:62], rfc8032Seed + "00"} {
+		if _, err := services.ParseStatementKey(seed); !errors.Is(err, services.ErrInvalidStatementKey) {
+			t.Errorf("ParseStatementKey(%q): err = %v, want ErrInvalidStatementKey", seed, err)
+		}
+	}
+}
+
+func TestVerifyStatement(t *testing.T) {
+	previous := services.StatementSigningKey
+	t.Cleanup(func() { services.StatementSigningKey = previous })
+	services.StatementSigningKey = nil
+	statement := services.Statement{AccountNumber: "1234567897", From: "2024-03-01", To: "2024-03-31", ClosingBalance: 120.5,
+		Lines: []services.StatementLine{{TransactionID: 1, Kind: "transfer", Counterparty: "2345678916", Amount: 120.5, Balance: 120.5}}}
+	if _, err := services.SignStatement(statement); !errors.Is(err, services.ErrNoStatementKey) {
+		t.Fatalf("without a key: err = %v, want ErrNoStatementKey", err)
+	}
+	services.StatementSigningKey, _ = services.ParseStatementKey(rfc8032Seed)
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+
+	tests := []struct {
+		name   string
+		tamper func(s *services.SignedStatement)
+		key    ed25519.PublicKey
+		ok     bool
+	}{
+		{"intact", func(*services.SignedStatement) {}, services.StatementPublicKey(), true},
+		{"amount changed", func(s *services.SignedStatement) {
+			s.Statement = bytes.Replace(s.Statement, []byte("120.5"), []byte("920.5"), 1)
+		}, services.StatementPublicKey(), false},
+		{"re-encoded with spaces", func(s *services.SignedStatement) {
+			s.Statement = bytes.Replace(s.Statement, []byte(`":`), []byte(`": `), 1)
+		}, services.StatementPublicKey(), false},
+		{"signature corrupted", func(s *services.SignedStatement) {
+			s.Signature = strings.ToLower(s.Signature)
+		}, services.StatementPublicKey(), false},
+		{"signature not base64", func(s *services.SignedStatement) { s.Signature = "!" }, services.StatementPublicKey(), false},
+		{"other algorithm", func(s *services.SignedStatement) { s.Algorithm = "RS256" }, services.StatementPublicKey(), false},
+		{"other key", func(*services.SignedStatement) {}, otherKey.Public().(ed25519.PublicKey), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := services.SignStatement(statement)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signed.KeyID != services.StatementKeyID(services.StatementPublicKey()) {
+				t.Errorf("key_id = %s", signed.KeyID)
+			}
+			tt.tamper(&signed)
+			got, err := services.VerifyStatement(signed, tt.key)
+			if tt.ok {
+				if err != nil || got.ClosingBalance != statement.ClosingBalance || len(got.Lines) != 1 {
+					t.Errorf("VerifyStatement() = %+v, %v", got, err)
+				}
+			} else if !errors.Is(err, services.ErrInvalidStatementSignature) {
+				t.Errorf("VerifyStatement(): err = %v, want ErrInvalidStatementSignature", err)
+			}
+		})
+	}
+}
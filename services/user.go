@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gotestbackend/database"
+	"gotestbackend/models"
+	"gotestbackend/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUserNotFound is returned when no user matches the lookup
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserLookup is returned when the database could not be queried
+	ErrUserLookup = errors.New("user lookup failed")
+	// ErrInvalidAccountNumber is returned for an account number whose check digit
+	// is wrong and which is not a grandfathered legacy number
+	ErrInvalidAccountNumber = errors.New("invalid account number")
+)
+
+// lookupError maps a gorm error onto ErrUserNotFound or ErrUserLookup
+func lookupError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUserNotFound
+	}
+	return fmt.Errorf("%w: %v", ErrUserLookup, err)
+}
+
+// GetUserByID loads a user by primary key
+func GetUserByID(db *gorm.DB, userID uint) (models.User, error) {
+	var user models.User
+	if db == nil {
+		db = database.DB
+	}
+	if err := db.First(&user, userID).Error; err != nil {
+		return models.User{}, lookupError(err)
+	}
+	return user, nil
+}
+
+// GetUserByAccount loads a user by account number. A number that fails the
+// check digit only matches accounts flagged as legacy; anything else is a
+// typo and returns ErrInvalidAccountNumber.
+func GetUserByAccount(db *gorm.DB, accountNumber string) (models.User, error) {
+	var user models.User
+	if db == nil {
+		db = database.DB
+	}
+	if accountNumber == "" {
+		return models.User{}, ErrUserNotFound
+	}
+	query := db.Where("account_number = ?", accountNumber)
+	legacyOnly := !utils.HasValidCheckDigit(accountNumber)
+	if legacyOnly {
+		query = query.Where("legacy_account_number = ?", true)
+	}
+	if err := query.First(&user).Error; err != nil {
+		if legacyOnly && errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.User{}, ErrInvalidAccountNumber
+		}
+		return models.User{}, lookupError(err)
+	}
+	return user, nil
+}
+
+// OpenCustomerAccount saves a new customer with no credit and a fresh account
+// number; any sign-up bonus is granted once a contact is verified. Run it inside a transaction; only the username, names and
+// password hash are taken from user, everything else is reset.
+func OpenCustomerAccount(tx *gorm.DB, user *models.User) error {
+	now := time.Now()
+	*user = models.User{
+		Username:       user.Username,
+		Password:       user.Password,
+		FirstName:      user.FirstName,
+		LastName:       user.LastName,
+		Role:           models.RoleUser,
+		AccountType:    models.AccountTypePersonal,
+		Status:         models.AccountActive,
+		LastActivityAt: &now,
+		OpenedAt:       &now,
+	}
+	accountNumber, err := AllocateAccountNumber(tx)
+	if err != nil {
+		return err
+	}
+	user.AccountNumber = accountNumber
+	return tx.Create(user).Error
+}
+
+// RestoreUser clears the soft-delete marker of a deleted user
+func RestoreUser(db *gorm.DB, userID uint) (models.User, error) {
+	var user models.User
+	if err := db.Unscoped().Where("deleted_at IS NOT NULL").First(&user, userID).Error; err != nil {
+		return models.User{}, lookupError(err)
+	}
+	if err := db.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
+		return models.User{}, fmt.Errorf("%w: %v", ErrUserLookup, err)
+	}
+	return GetUserByID(db, userID)
+}
@@ -0,0 +1,44 @@
+package utils
+
+// AccountNumberLength is the number of digits in an account number, including the check digit
+const AccountNumberLength = 10
+
+// isDigits reports whether s is non-empty and made only of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// LuhnCheckDigit returns the Luhn (mod 10) check digit for a string of digits
+func LuhnCheckDigit(base string) byte {
+	sum := 0
+	double := true
+	for i := len(base) - 1; i >= 0; i-- {
+		d := int(base[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// HasValidCheckDigit reports whether a 10-digit account number ends in its Luhn check digit
+func HasValidCheckDigit(accountNumber string) bool {
+	if len(accountNumber) != AccountNumberLength || !isDigits(accountNumber) {
+		return false
+	}
+	last := len(accountNumber) - 1
+	return LuhnCheckDigit(accountNumber[:last]) == accountNumber[last]
+}
@@ -0,0 +1,46 @@
+package utils_test
+
+import (
+	"testing"
+
+	"gotestbackend/utils"
+)
+
+func TestLuhnCheckDigit(t *testing.T) {
+	tests := []struct {
+		base string
+		want byte
+	}{
+		{"000000000", '0'},
+		{"123456789", '7'},
+		{"799273987", '5'},
+		{"7992739871", '3'},
+		{"411111111111111", '1'},
+	}
+	for _, tt := range tests {
+		if got := utils.LuhnCheckDigit(tt.base); got != tt.want {
+			t.Errorf("LuhnCheckDigit(%q) = %c, want %c", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestHasValidCheckDigit(t *testing.T) {
+	tests := []struct {
+		number string
+		want   bool
+	}{
+		{"1234567897", true},
+		{"1000000016", true},
+		{"1234567898", false},
+		{"2134567897", false},  // swapped digits
+		{"123456789", false},   // too short
+		{"12345678970", false}, // too long
+		{"12345a7897", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := utils.HasValidCheckDigit(tt.number); got != tt.want {
+			t.Errorf("HasValidCheckDigit(%q) = %v, want %v", tt.number, got, tt.want)
+		}
+	}
+}
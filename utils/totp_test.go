@@ -0,0 +1,69 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"gotestbackend/utils"
+)
+
+// rfc6238Secret is the SHA-1 test key of RFC 6238, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; the 6-digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := utils.TOTPCode(rfc6238Secret, utils.TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+	// Lower case and padded secrets decode the same
+	if got, _ := utils.TOTPCode("gezdgnbvgy3tqojqgezdgnbvgy3tqojq====", 1); got != "287082" {
+		t.Errorf("lower-case secret: got %s", got)
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := utils.TOTPStep(now)
+	code := func(step int64) string {
+		c, _ := utils.TOTPCode(rfc6238Secret, step)
+		return c
+	}
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+		step int64
+	}{
+		{"current step", code(step), true, step},
+		{"one step behind", code(step - 1), true, step - 1},
+		{"one step ahead", code(step + 1), true, step + 1},
+		{"two steps behind", code(step - 2), false, 0},
+		{"too short", code(step)[:5], false, 0},
+		{"not digits", "12345a", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := utils.MatchTOTP(rfc6238Secret, tt.code, now, 1)
+			if ok != tt.ok || got != tt.step {
+				t.Errorf("MatchTOTP() = %d, %v, want %d, %v", got, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}